@@ -1,15 +1,88 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/Chemiseblanc/gonews/nntp"
+	"github.com/Chemiseblanc/gonews/nntp/auth"
+	"github.com/Chemiseblanc/gonews/nntp/storage"
 )
 
 func main() {
-	srv, err := nntp.NewServer(":119", nil)
+	addr := flag.String("addr", ":119", "address to listen on")
+	readersConf := flag.String("readers-conf", "", "INN style readers.conf file controlling client access")
+	spool := flag.String("spool", "", "root of an INN style tradspool news spool, articles are kept in memory if unset")
+	groups := flag.String("groups", "", "comma separated list of groups to create when articles are kept in memory")
+	tlsAddr := flag.String("tls-addr", "", "address to accept implicit TLS connections on, such as :563")
+	tlsCert := flag.String("tls-cert", "", "certificate file used for STARTTLS and implicit TLS connections")
+	tlsKey := flag.String("tls-key", "", "private key file of the TLS certificate")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "time given to clients to finish their commands when shutting down")
+	maxConns := flag.Int("max-connections", 0, "maximum number of simultaneous clients, 0 for no limit")
+	flag.Parse()
+
+	var options []nntp.ServerOption
+	if *spool != "" {
+		options = append(options, nntp.WithStorage(storage.NewLegacyFileSystem(*spool)))
+	} else {
+		m := storage.NewMemory()
+		for _, name := range strings.Split(*groups, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				m.AddGroup(nntp.Group{Name: name})
+			}
+		}
+		options = append(options, nntp.WithStorage(m))
+	}
+	if *readersConf != "" {
+		a, err := auth.LoadReadersConf(*readersConf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options = append(options, nntp.WithAuth(a))
+	}
+
+	var config *tls.Config
+	if *tlsCert != "" {
+		cert, err := tls.LoadX509KeyPair(*tlsCert, *tlsKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		config = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+	if *tlsAddr != "" {
+		options = append(options, nntp.WithImplicitTLS(*tlsAddr))
+	}
+
+	srv, err := nntp.NewServer(*addr, config, options...)
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Fatal(srv.ListenAndServe())
+	srv.MaxConnections = *maxConns
+
+	// Stop accepting connections on SIGINT or SIGTERM and give clients time to finish transferring articles
+	done := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		ctx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Println(err)
+		}
+		close(done)
+	}()
+
+	if err := srv.ListenAndServe(); err != nntp.ErrServerClosed {
+		log.Fatal(err)
+	}
+	<-done
 }
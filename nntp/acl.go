@@ -0,0 +1,126 @@
+package nntp
+
+import "strings"
+
+// Permission is a set of actions a client may take on a newsgroup
+type Permission uint
+
+const (
+	// PermissionSee makes a group visible to the client, without it the group behaves as if it doesn't exist
+	PermissionSee Permission = 1 << iota
+	// PermissionRead allows the client to select the group and retrieve its articles
+	PermissionRead
+	// PermissionPost allows the client to post articles to the group
+	PermissionPost
+	// PermissionNewnews allows the client to list the articles that arrived in the group with NEWNEWS
+	PermissionNewnews
+
+	PermissionAll = PermissionSee | PermissionRead | PermissionPost | PermissionNewnews
+)
+
+// AccessRule grants a set of permissions on the groups matching a wildmat
+type AccessRule struct {
+	Groups      string
+	Permissions Permission
+}
+
+// ACL is a list of access rules whose permissions are combined for each group they match, exclusions are expressed
+// with negated wildmat patterns. A group matched by no rule is hidden, while a nil ACL places no restrictions on the client
+type ACL []AccessRule
+
+// Permissions returns the union of the permissions granted on a group by every rule matching it
+func (acl ACL) Permissions(group string) Permission {
+	if acl == nil {
+		return PermissionAll
+	}
+	var p Permission
+	for _, rule := range acl {
+		if MatchWildmat(rule.Groups, group) {
+			p |= rule.Permissions
+		}
+	}
+	return p
+}
+
+// Allowed reports whether all of the given permissions are granted on a group
+func (acl ACL) Allowed(group string, p Permission) bool {
+	return acl.Permissions(group)&p == p
+}
+
+// Grants reports whether any rule grants all of the given permissions on some group
+func (acl ACL) Grants(p Permission) bool {
+	if acl == nil {
+		return true
+	}
+	for _, rule := range acl {
+		if rule.Permissions&p == p {
+			return true
+		}
+	}
+	return false
+}
+
+// articleGroups returns the names of the groups listed in the Newsgroups header of an article
+func articleGroups(a *Article) []string {
+	var groups []string
+	for _, g := range strings.Split(a.Get("Newsgroups"), ",") {
+		if g = strings.TrimSpace(g); g != "" {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// articleReadable reports whether the client may read an article, which requires it to be able
+// to read at least one of the groups the article was posted to
+func articleReadable(c *Conn, a *Article) bool {
+	acl := c.Access()
+	if acl == nil {
+		return true
+	}
+	for _, g := range articleGroups(a) {
+		if acl.Allowed(g, PermissionSee|PermissionRead) {
+			return true
+		}
+	}
+	return false
+}
+
+// overviewGroups returns the names of the groups listed in the Xref field of an overview entry
+func overviewGroups(ov *Overview) []string {
+	var groups []string
+	fields := strings.Fields(ov.Xref)
+	if len(fields) > 0 {
+		// The first field is the name of the server that assigned the article numbers
+		fields = fields[1:]
+	}
+	for _, f := range fields {
+		if i := strings.LastIndex(f, ":"); i > 0 {
+			groups = append(groups, f[:i])
+		}
+	}
+	return groups
+}
+
+// overviewReadable reports whether the client may read the article an overview entry describes, falling back
+// to the article's Newsgroups header when the entry has no Xref field to take its groups from
+func overviewReadable(c *Conn, ov *Overview) (bool, error) {
+	acl := c.Access()
+	if acl == nil {
+		return true, nil
+	}
+	groups := overviewGroups(ov)
+	if len(groups) == 0 {
+		a, err := c.StorageBackend().ArticleByID(c.Context(), ov.MessageID)
+		if err != nil || a == nil {
+			return false, err
+		}
+		return articleReadable(c, a), nil
+	}
+	for _, g := range groups {
+		if acl.Allowed(g, PermissionSee|PermissionRead) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,56 @@
+package nntp
+
+import "testing"
+
+func TestACLPermissions(t *testing.T) {
+	acl := ACL{
+		{Groups: "comp.*,misc.*", Permissions: PermissionSee | PermissionRead},
+		{Groups: "comp.*,!comp.announce", Permissions: PermissionSee | PermissionPost},
+		{Groups: "misc.secret", Permissions: 0},
+	}
+	tests := []struct {
+		group string
+		want  Permission
+	}{
+		// Permissions granted by every matching rule are combined
+		{"comp.lang.go", PermissionSee | PermissionRead | PermissionPost},
+		// A negated pattern excludes a group from a rule without revoking what other rules grant
+		{"comp.announce", PermissionSee | PermissionRead},
+		// A rule granting nothing doesn't override an earlier broader rule
+		{"misc.secret", PermissionSee | PermissionRead},
+		{"alt.test", 0},
+	}
+	for _, test := range tests {
+		if got := acl.Permissions(test.group); got != test.want {
+			t.Errorf("Permissions(%q) = %b, want %b", test.group, got, test.want)
+		}
+	}
+
+	if !acl.Allowed("comp.lang.go", PermissionRead|PermissionPost) {
+		t.Error("read and post on comp.lang.go should be allowed by the combination of two rules")
+	}
+	if acl.Allowed("comp.announce", PermissionPost) {
+		t.Error("posting to comp.announce should not be allowed")
+	}
+}
+
+func TestACLNil(t *testing.T) {
+	var acl ACL
+	if got := acl.Permissions("any.group"); got != PermissionAll {
+		t.Errorf("nil ACL granted %b, want every permission", got)
+	}
+	if got := (ACL{}).Permissions("any.group"); got != 0 {
+		t.Errorf("empty ACL granted %b, want no permissions", got)
+	}
+}
+
+func TestOverviewGroups(t *testing.T) {
+	ov := &Overview{Xref: "news.example.com comp.lang.go:12 misc.test:3"}
+	got := overviewGroups(ov)
+	if len(got) != 2 || got[0] != "comp.lang.go" || got[1] != "misc.test" {
+		t.Errorf("overviewGroups = %q, want [comp.lang.go misc.test]", got)
+	}
+	if got := overviewGroups(&Overview{}); len(got) != 0 {
+		t.Errorf("overviewGroups of an entry without Xref = %q, want none", got)
+	}
+}
@@ -0,0 +1,416 @@
+package auth
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedHash is returned when a password file entry uses a crypt(3) scheme that isn't implemented
+var ErrUnsupportedHash = errors.New("auth: unsupported password hash scheme")
+
+// cryptAlphabet is the base64 alphabet used by crypt(3)
+const cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// checkPassword compares a password against a crypt(3) hash as found in passwd style files. Traditional DES,
+// MD5 ($1$), SHA-256 ($5$) and SHA-512 ($6$) hashes are supported, and entries beginning with '*' or '!' are locked
+func checkPassword(hashed string, password string) (bool, error) {
+	var computed string
+	switch {
+	case strings.HasPrefix(hashed, "*") || strings.HasPrefix(hashed, "!"):
+		return false, nil
+	case isDESHash(hashed):
+		computed = desCrypt([]byte(password), hashed[:2])
+	case strings.HasPrefix(hashed, "$1$"):
+		computed = md5Crypt([]byte(password), cryptSalt(hashed[3:], 8))
+	case strings.HasPrefix(hashed, "$5$"):
+		computed = shaCrypt(sha256.New, "$5$", sha256Order, []byte(password), hashed[3:])
+	case strings.HasPrefix(hashed, "$6$"):
+		computed = shaCrypt(sha512.New, "$6$", sha512Order, []byte(password), hashed[3:])
+	default:
+		return false, ErrUnsupportedHash
+	}
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(hashed)) == 1, nil
+}
+
+// cryptSalt extracts the salt from the part of a hash following the scheme identifier
+func cryptSalt(s string, max int) string {
+	if i := strings.IndexByte(s, '$'); i >= 0 {
+		s = s[:i]
+	}
+	if len(s) > max {
+		s = s[:max]
+	}
+	return s
+}
+
+// cryptEncode encodes groups of three bytes taken from sum in the given order using the crypt(3) base64 alphabet.
+// A trailing group shorter than three bytes is padded with leading zeros and produces fewer characters
+func cryptEncode(sum []byte, order [][]int) string {
+	var b strings.Builder
+	for _, group := range order {
+		var w uint
+		for _, i := range group {
+			w = w<<8 | uint(sum[i])
+		}
+		for n := len(group) + 1; n > 0; n-- {
+			b.WriteByte(cryptAlphabet[w&0x3f])
+			w >>= 6
+		}
+	}
+	return b.String()
+}
+
+var md5Order = [][]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}, {11}}
+
+// md5Crypt implements the MD5 based crypt(3) scheme originating from FreeBSD
+func md5Crypt(password []byte, salt string) string {
+	alternate := md5.New()
+	alternate.Write(password)
+	alternate.Write([]byte(salt))
+	alternate.Write(password)
+	final := alternate.Sum(nil)
+
+	ctx := md5.New()
+	ctx.Write(password)
+	ctx.Write([]byte("$1$"))
+	ctx.Write([]byte(salt))
+	for n := len(password); n > 0; n -= 16 {
+		if n > 16 {
+			ctx.Write(final)
+		} else {
+			ctx.Write(final[:n])
+		}
+	}
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 == 1 {
+			ctx.Write([]byte{0})
+		} else {
+			ctx.Write(password[:1])
+		}
+	}
+	final = ctx.Sum(nil)
+
+	for i := 0; i < 1000; i++ {
+		round := md5.New()
+		if i&1 == 1 {
+			round.Write(password)
+		} else {
+			round.Write(final)
+		}
+		if i%3 != 0 {
+			round.Write([]byte(salt))
+		}
+		if i%7 != 0 {
+			round.Write(password)
+		}
+		if i&1 == 1 {
+			round.Write(final)
+		} else {
+			round.Write(password)
+		}
+		final = round.Sum(nil)
+	}
+
+	return "$1$" + salt + "$" + cryptEncode(final, md5Order)
+}
+
+var sha256Order = [][]int{
+	{0, 10, 20}, {21, 1, 11}, {12, 22, 2}, {3, 13, 23}, {24, 4, 14},
+	{15, 25, 5}, {6, 16, 26}, {27, 7, 17}, {18, 28, 8}, {9, 19, 29},
+	{31, 30},
+}
+
+var sha512Order = [][]int{
+	{0, 21, 42}, {22, 43, 1}, {44, 2, 23}, {3, 24, 45}, {25, 46, 4},
+	{47, 5, 26}, {6, 27, 48}, {28, 49, 7}, {50, 8, 29}, {9, 30, 51},
+	{31, 52, 10}, {53, 11, 32}, {12, 33, 54}, {34, 55, 13}, {56, 14, 35},
+	{15, 36, 57}, {37, 58, 16}, {59, 17, 38}, {18, 39, 60}, {40, 61, 19},
+	{62, 20, 41}, {63},
+}
+
+// shaCrypt implements the SHA-256 and SHA-512 based crypt(3) schemes specified by Ulrich Drepper,
+// params is the part of the hash following the scheme identifier
+func shaCrypt(newHash func() hash.Hash, prefix string, order [][]int, password []byte, params string) string {
+	rounds := 5000
+	explicitRounds := false
+	if strings.HasPrefix(params, "rounds=") {
+		end := strings.IndexByte(params, '$')
+		if end < 0 {
+			end = len(params)
+		}
+		if n, err := strconv.Atoi(params[len("rounds="):end]); err == nil {
+			rounds = n
+			if rounds < 1000 {
+				rounds = 1000
+			} else if rounds > 999999999 {
+				rounds = 999999999
+			}
+			explicitRounds = true
+		}
+		if end < len(params) {
+			end++
+		}
+		params = params[end:]
+	}
+	salt := []byte(cryptSalt(params, 16))
+
+	alternate := newHash()
+	alternate.Write(password)
+	alternate.Write(salt)
+	alternate.Write(password)
+	altSum := alternate.Sum(nil)
+
+	ctx := newHash()
+	ctx.Write(password)
+	ctx.Write(salt)
+	n := len(password)
+	for ; n > len(altSum); n -= len(altSum) {
+		ctx.Write(altSum)
+	}
+	ctx.Write(altSum[:n])
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 == 1 {
+			ctx.Write(altSum)
+		} else {
+			ctx.Write(password)
+		}
+	}
+	sum := ctx.Sum(nil)
+
+	dp := newHash()
+	for i := 0; i < len(password); i++ {
+		dp.Write(password)
+	}
+	pSeq := repeatTo(dp.Sum(nil), len(password))
+
+	ds := newHash()
+	for i := 0; i < 16+int(sum[0]); i++ {
+		ds.Write(salt)
+	}
+	sSeq := repeatTo(ds.Sum(nil), len(salt))
+
+	for i := 0; i < rounds; i++ {
+		round := newHash()
+		if i&1 == 1 {
+			round.Write(pSeq)
+		} else {
+			round.Write(sum)
+		}
+		if i%3 != 0 {
+			round.Write(sSeq)
+		}
+		if i%7 != 0 {
+			round.Write(pSeq)
+		}
+		if i&1 == 1 {
+			round.Write(sum)
+		} else {
+			round.Write(pSeq)
+		}
+		sum = round.Sum(nil)
+	}
+
+	result := prefix
+	if explicitRounds {
+		result += "rounds=" + strconv.Itoa(rounds) + "$"
+	}
+	return result + string(salt) + "$" + cryptEncode(sum, order)
+}
+
+// repeatTo repeats b until it is n bytes long
+func repeatTo(b []byte, n int) []byte {
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		rest := n - len(out)
+		if rest > len(b) {
+			rest = len(b)
+		}
+		out = append(out, b[:rest]...)
+	}
+	return out
+}
+
+// isDESHash reports whether a hash is in the traditional DES based crypt(3) format,
+// two salt characters followed by eleven characters encoding the result
+func isDESHash(hashed string) bool {
+	if len(hashed) != 13 {
+		return false
+	}
+	for i := 0; i < len(hashed); i++ {
+		if strings.IndexByte(cryptAlphabet, hashed[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// DES tables from FIPS 46-3, bit positions are numbered from 1 at the most significant bit
+var (
+	desInitialPermutation = []byte{
+		58, 50, 42, 34, 26, 18, 10, 2, 60, 52, 44, 36, 28, 20, 12, 4,
+		62, 54, 46, 38, 30, 22, 14, 6, 64, 56, 48, 40, 32, 24, 16, 8,
+		57, 49, 41, 33, 25, 17, 9, 1, 59, 51, 43, 35, 27, 19, 11, 3,
+		61, 53, 45, 37, 29, 21, 13, 5, 63, 55, 47, 39, 31, 23, 15, 7,
+	}
+	desFinalPermutation = []byte{
+		40, 8, 48, 16, 56, 24, 64, 32, 39, 7, 47, 15, 55, 23, 63, 31,
+		38, 6, 46, 14, 54, 22, 62, 30, 37, 5, 45, 13, 53, 21, 61, 29,
+		36, 4, 44, 12, 52, 20, 60, 28, 35, 3, 43, 11, 51, 19, 59, 27,
+		34, 2, 42, 10, 50, 18, 58, 26, 33, 1, 41, 9, 49, 17, 57, 25,
+	}
+	desExpansion = []byte{
+		32, 1, 2, 3, 4, 5, 4, 5, 6, 7, 8, 9, 8, 9, 10, 11,
+		12, 13, 12, 13, 14, 15, 16, 17, 16, 17, 18, 19, 20, 21, 20, 21,
+		22, 23, 24, 25, 24, 25, 26, 27, 28, 29, 28, 29, 30, 31, 32, 1,
+	}
+	desPermutation = []byte{
+		16, 7, 20, 21, 29, 12, 28, 17, 1, 15, 23, 26, 5, 18, 31, 10,
+		2, 8, 24, 14, 32, 27, 3, 9, 19, 13, 30, 6, 22, 11, 4, 25,
+	}
+	desPermutedChoice1 = []byte{
+		57, 49, 41, 33, 25, 17, 9, 1, 58, 50, 42, 34, 26, 18,
+		10, 2, 59, 51, 43, 35, 27, 19, 11, 3, 60, 52, 44, 36,
+		63, 55, 47, 39, 31, 23, 15, 7, 62, 54, 46, 38, 30, 22,
+		14, 6, 61, 53, 45, 37, 29, 21, 13, 5, 28, 20, 12, 4,
+	}
+	desPermutedChoice2 = []byte{
+		14, 17, 11, 24, 1, 5, 3, 28, 15, 6, 21, 10,
+		23, 19, 12, 4, 26, 8, 16, 7, 27, 20, 13, 2,
+		41, 52, 31, 37, 47, 55, 30, 40, 51, 45, 33, 48,
+		44, 49, 39, 56, 34, 53, 46, 42, 50, 36, 29, 32,
+	}
+	desShifts = []uint{1, 1, 2, 2, 2, 2, 2, 2, 1, 2, 2, 2, 2, 2, 2, 1}
+	desSBoxes = [8][64]byte{
+		{
+			14, 4, 13, 1, 2, 15, 11, 8, 3, 10, 6, 12, 5, 9, 0, 7,
+			0, 15, 7, 4, 14, 2, 13, 1, 10, 6, 12, 11, 9, 5, 3, 8,
+			4, 1, 14, 8, 13, 6, 2, 11, 15, 12, 9, 7, 3, 10, 5, 0,
+			15, 12, 8, 2, 4, 9, 1, 7, 5, 11, 3, 14, 10, 0, 6, 13,
+		},
+		{
+			15, 1, 8, 14, 6, 11, 3, 4, 9, 7, 2, 13, 12, 0, 5, 10,
+			3, 13, 4, 7, 15, 2, 8, 14, 12, 0, 1, 10, 6, 9, 11, 5,
+			0, 14, 7, 11, 10, 4, 13, 1, 5, 8, 12, 6, 9, 3, 2, 15,
+			13, 8, 10, 1, 3, 15, 4, 2, 11, 6, 7, 12, 0, 5, 14, 9,
+		},
+		{
+			10, 0, 9, 14, 6, 3, 15, 5, 1, 13, 12, 7, 11, 4, 2, 8,
+			13, 7, 0, 9, 3, 4, 6, 10, 2, 8, 5, 14, 12, 11, 15, 1,
+			13, 6, 4, 9, 8, 15, 3, 0, 11, 1, 2, 12, 5, 10, 14, 7,
+			1, 10, 13, 0, 6, 9, 8, 7, 4, 15, 14, 3, 11, 5, 2, 12,
+		},
+		{
+			7, 13, 14, 3, 0, 6, 9, 10, 1, 2, 8, 5, 11, 12, 4, 15,
+			13, 8, 11, 5, 6, 15, 0, 3, 4, 7, 2, 12, 1, 10, 14, 9,
+			10, 6, 9, 0, 12, 11, 7, 13, 15, 1, 3, 14, 5, 2, 8, 4,
+			3, 15, 0, 6, 10, 1, 13, 8, 9, 4, 5, 11, 12, 7, 2, 14,
+		},
+		{
+			2, 12, 4, 1, 7, 10, 11, 6, 8, 5, 3, 15, 13, 0, 14, 9,
+			14, 11, 2, 12, 4, 7, 13, 1, 5, 0, 15, 10, 3, 9, 8, 6,
+			4, 2, 1, 11, 10, 13, 7, 8, 15, 9, 12, 5, 6, 3, 0, 14,
+			11, 8, 12, 7, 1, 14, 2, 13, 6, 15, 0, 9, 10, 4, 5, 3,
+		},
+		{
+			12, 1, 10, 15, 9, 2, 6, 8, 0, 13, 3, 4, 14, 7, 5, 11,
+			10, 15, 4, 2, 7, 12, 9, 5, 6, 1, 13, 14, 0, 11, 3, 8,
+			9, 14, 15, 5, 2, 8, 12, 3, 7, 0, 4, 10, 1, 13, 11, 6,
+			4, 3, 2, 12, 9, 5, 15, 10, 11, 14, 1, 7, 6, 0, 8, 13,
+		},
+		{
+			4, 11, 2, 14, 15, 0, 8, 13, 3, 12, 9, 7, 5, 10, 6, 1,
+			13, 0, 11, 7, 4, 9, 1, 10, 14, 3, 5, 12, 2, 15, 8, 6,
+			1, 4, 11, 13, 12, 3, 7, 14, 10, 15, 6, 8, 0, 5, 9, 2,
+			6, 11, 13, 8, 1, 4, 10, 7, 9, 5, 0, 15, 14, 2, 3, 12,
+		},
+		{
+			13, 2, 8, 4, 6, 15, 11, 1, 10, 9, 3, 14, 5, 0, 12, 7,
+			1, 15, 13, 8, 10, 3, 7, 4, 12, 5, 6, 11, 0, 14, 9, 2,
+			7, 11, 4, 1, 9, 12, 14, 2, 0, 6, 10, 13, 15, 3, 5, 8,
+			2, 1, 14, 7, 4, 10, 8, 13, 15, 12, 9, 0, 3, 5, 6, 11,
+		},
+	}
+)
+
+// permute rearranges the bits of an inBits wide value according to a table of bit positions
+func permute(in uint64, inBits uint, table []byte) uint64 {
+	var out uint64
+	for _, pos := range table {
+		out = out<<1 | (in>>(inBits-uint(pos)))&1
+	}
+	return out
+}
+
+// desCrypt implements the traditional DES based crypt(3) scheme from Seventh Edition Unix. The first eight
+// characters of the password form the key, which encrypts a zero block 25 times using a variant of DES whose
+// expansion is perturbed by the two character salt
+func desCrypt(password []byte, salt string) string {
+	var key uint64
+	for i := 0; i < 8; i++ {
+		key <<= 8
+		if i < len(password) {
+			key |= uint64(password[i]<<1) & 0xff
+		}
+	}
+
+	// Each set bit of the salt swaps a pair of bits in the expansion of the right half of the block
+	expansion := make([]byte, len(desExpansion))
+	copy(expansion, desExpansion)
+	for i := 0; i < 2; i++ {
+		bits := strings.IndexByte(cryptAlphabet, salt[i])
+		for j := 0; j < 6; j++ {
+			if bits>>uint(j)&1 == 1 {
+				k := 6*i + j
+				expansion[k], expansion[k+24] = expansion[k+24], expansion[k]
+			}
+		}
+	}
+
+	var subkeys [16]uint64
+	cd := permute(key, 64, desPermutedChoice1)
+	c, d := cd>>28, cd&0xfffffff
+	for i, shift := range desShifts {
+		c = (c<<shift | c>>(28-shift)) & 0xfffffff
+		d = (d<<shift | d>>(28-shift)) & 0xfffffff
+		subkeys[i] = permute(c<<28|d, 56, desPermutedChoice2)
+	}
+
+	var block uint64
+	for n := 0; n < 25; n++ {
+		block = permute(block, 64, desInitialPermutation)
+		l, r := block>>32, block&0xffffffff
+		for _, subkey := range subkeys {
+			x := permute(r, 32, expansion) ^ subkey
+			var f uint64
+			for i := 0; i < 8; i++ {
+				six := x >> uint(42-6*i) & 0x3f
+				row := six>>4&2 | six&1
+				col := six >> 1 & 0xf
+				f = f<<4 | uint64(desSBoxes[i][row*16+col])
+			}
+			l, r = r, l^permute(f, 32, desPermutation)
+		}
+		block = permute(r<<32|l, 64, desFinalPermutation)
+	}
+
+	// The 64 bit result is padded to 66 bits and encoded six bits at a time
+	var b strings.Builder
+	b.WriteString(salt)
+	for i := 0; i < 11; i++ {
+		shift := 58 - 6*i
+		var six uint64
+		if shift >= 0 {
+			six = block >> uint(shift) & 0x3f
+		} else {
+			six = block << uint(-shift) & 0x3f
+		}
+		b.WriteByte(cryptAlphabet[six])
+	}
+	return b.String()
+}
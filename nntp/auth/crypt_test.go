@@ -0,0 +1,52 @@
+package auth
+
+import "testing"
+
+func TestCheckPassword(t *testing.T) {
+	tests := []struct {
+		hashed   string
+		password string
+		want     bool
+	}{
+		{"abNANd1rDfiNc", "secret", true},
+		{"abNANd1rDfiNc", "Secret", false},
+		{"aaqPiZY5xR5l.", "test", true},
+		{"..X8NBuQ4l6uQ", "", true},
+		// Only the first eight characters of a password are significant to DES crypt
+		{"Zz8Wi8UN4F4xY", "a much longer password", true},
+		{"Zz8Wi8UN4F4xY", "a much l", true},
+		{"Zz8Wi8UN4F4xY", "a much", false},
+
+		{md5Secret, "secret", true},
+		{md5Secret, "secreT", false},
+		{"$5$saltstring$C3o4O1TC6aRHF4FI.QSZMXtHbaj2gSXr4sUc/3NcUi.", "secret", true},
+		{"$5$rounds=1000$saltstring$AH5PD0i.riCRu4BNDy9v7OPV7u3dfLBApcXI6khquVA", "secret", true},
+		{"$5$rounds=1000$saltstring$AH5PD0i.riCRu4BNDy9v7OPV7u3dfLBApcXI6khquVA", "wrong", false},
+		{"$6$saltstring$AIsRs/Ee56G/tC8MEHhvReZTfx8u3rXXMl6eYrjCG9ibix19DxoMBLogdTET5Ukw9Sf7eZTITsuk0Ry5qulYz.", "secret", true},
+		{"$6$rounds=1000$saltstring$2BkAo106CG84raQcWpAlhyy6TSSkAsL1nfvBCoezLYyerUzY2l8axTvq6NynEt7SQAx3/cdop9ds0po8uaeHq0", "secret", true},
+	}
+	for _, test := range tests {
+		got, err := checkPassword(test.hashed, test.password)
+		if err != nil {
+			t.Errorf("checkPassword(%q, %q) failed: %v", test.hashed, test.password, err)
+		} else if got != test.want {
+			t.Errorf("checkPassword(%q, %q) = %v, want %v", test.hashed, test.password, got, test.want)
+		}
+	}
+}
+
+func TestCheckPasswordLocked(t *testing.T) {
+	for _, hashed := range []string{"*", "!", "!abNANd1rDfiNc"} {
+		if ok, err := checkPassword(hashed, "secret"); ok || err != nil {
+			t.Errorf("checkPassword(%q) = %v, %v, want a locked account to be refused", hashed, ok, err)
+		}
+	}
+}
+
+func TestCheckPasswordUnsupported(t *testing.T) {
+	for _, hashed := range []string{"", "$2b$10$abcdefghijklmnopqrstuv", "abNANd1rDfiN", "ab NANd1rDfiN"} {
+		if _, err := checkPassword(hashed, "secret"); err != ErrUnsupportedHash {
+			t.Errorf("checkPassword(%q) error = %v, want ErrUnsupportedHash", hashed, err)
+		}
+	}
+}
@@ -0,0 +1,489 @@
+// Package auth provides implementations of the nntp.Auth interface
+package auth
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Chemiseblanc/gonews/nntp"
+)
+
+// ReadersConf is an nntp.Auth backend configured by an INN style readers.conf file.
+//
+// Clients are matched against auth blocks by the hosts key, and authenticate with the password files
+// named by "ckpasswd -f" auth programs. Access blocks are matched by the users and key keys and grant read and
+// post permissions with the newsgroups, read, post and access keys, where the access flags R, P and N permit
+// reading, posting and NEWNEWS respectively. Evaluation depends only on the client's
+// identity and address, never on the local time or time zone of the server. Feeds are configured separately
+// by INN so clients are never permitted to transfer articles with IHAVE.
+type ReadersConf struct {
+	authBlocks   []authBlock
+	accessBlocks []accessBlock
+
+	// LookupAddr resolves a client address to host names when matching hosts patterns that aren't addresses,
+	// it defaults to net.LookupAddr
+	LookupAddr func(string) ([]string, error)
+}
+
+// authBlock is an auth block of readers.conf which identifies clients
+type authBlock struct {
+	name            string
+	hosts           string
+	passwordFiles   []string
+	defaultIdentity string
+	defaultDomain   string
+	key             string
+}
+
+// identity returns the identity the block assigns to a user who authenticated with it,
+// which has the default domain appended unless the user name already has a domain
+func (b *authBlock) identity(username string) string {
+	if b.defaultDomain != "" && !strings.Contains(username, "@") {
+		return username + "@" + b.defaultDomain
+	}
+	return username
+}
+
+// accessBlock is an access block of readers.conf which grants permissions to identified clients
+type accessBlock struct {
+	name  string
+	users string
+	key   string
+	read  string
+	post  string
+	// newnews is true if the client may use NEWNEWS on the groups it can read
+	newnews bool
+}
+
+// LoadReadersConf reads and parses a readers.conf file
+func LoadReadersConf(path string) (*ReadersConf, error) {
+	r := &ReadersConf{LookupAddr: net.LookupAddr}
+	if err := r.include(path, 0); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// ParseReadersConf parses readers.conf formatted text, include directives are resolved relative to the working directory
+func ParseReadersConf(rd io.Reader) (*ReadersConf, error) {
+	r := &ReadersConf{LookupAddr: net.LookupAddr}
+	if err := r.parse(rd, "readers.conf", ".", 0); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// maxIncludeDepth bounds nested include directives so that include loops are reported instead of recursing forever
+const maxIncludeDepth = 10
+
+func (r *ReadersConf) include(path string, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: include nested too deeply", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return r.parse(f, path, filepath.Dir(path), depth)
+}
+
+// token is a lexical element of readers.conf along with the line it was found on
+type token struct {
+	text   string
+	quoted bool
+	line   int
+}
+
+// tokenize splits readers.conf text into words, quoted strings and braces, discarding comments
+func tokenize(rd io.Reader) ([]token, error) {
+	var tokens []token
+	s := bufio.NewScanner(rd)
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		for i := 0; i < len(text); {
+			ch := text[i]
+			switch {
+			case ch == '#':
+				i = len(text)
+			case ch == ' ' || ch == '\t' || ch == '\r':
+				i++
+			case ch == '{' || ch == '}':
+				tokens = append(tokens, token{text: string(ch), line: line})
+				i++
+			case ch == '"':
+				end := strings.IndexByte(text[i+1:], '"')
+				if end < 0 {
+					return nil, fmt.Errorf("line %d: unterminated quoted string", line)
+				}
+				tokens = append(tokens, token{text: text[i+1 : i+1+end], quoted: true, line: line})
+				i += end + 2
+			default:
+				end := strings.IndexAny(text[i:], " \t\r{}\"#")
+				if end < 0 {
+					end = len(text) - i
+				}
+				tokens = append(tokens, token{text: text[i : i+end], line: line})
+				i += end
+			}
+		}
+	}
+	return tokens, s.Err()
+}
+
+func (r *ReadersConf) parse(rd io.Reader, name string, dir string, depth int) error {
+	tokens, err := tokenize(rd)
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	for i := 0; i < len(tokens); {
+		t := tokens[i]
+		switch {
+		case !t.quoted && t.text == "include":
+			if i+1 >= len(tokens) {
+				return fmt.Errorf("%s:%d: include without a file name", name, t.line)
+			}
+			path := tokens[i+1].text
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(dir, path)
+			}
+			if err := r.include(path, depth+1); err != nil {
+				return err
+			}
+			i += 2
+		case !t.quoted && (t.text == "auth" || t.text == "access"):
+			if i+2 >= len(tokens) || tokens[i+2].text != "{" || tokens[i+2].quoted {
+				return fmt.Errorf("%s:%d: expected %s <name> {", name, t.line, t.text)
+			}
+			blockName := tokens[i+1].text
+			params := make(map[string][]string)
+			i += 3
+			for {
+				if i >= len(tokens) {
+					return fmt.Errorf("%s:%d: unterminated %s block %q", name, t.line, t.text, blockName)
+				}
+				if tokens[i].text == "}" && !tokens[i].quoted {
+					i++
+					break
+				}
+				key := tokens[i]
+				if key.quoted || !strings.HasSuffix(key.text, ":") || i+1 >= len(tokens) {
+					return fmt.Errorf("%s:%d: expected <key>: <value>", name, key.line)
+				}
+				k := strings.ToLower(strings.TrimSuffix(key.text, ":"))
+				params[k] = append(params[k], tokens[i+1].text)
+				i += 2
+			}
+			if t.text == "auth" {
+				block, err := newAuthBlock(blockName, params, dir)
+				if err != nil {
+					return fmt.Errorf("%s:%d: %v", name, t.line, err)
+				}
+				r.authBlocks = append(r.authBlocks, block)
+			} else {
+				r.accessBlocks = append(r.accessBlocks, newAccessBlock(blockName, params))
+			}
+		default:
+			return fmt.Errorf("%s:%d: unexpected %q", name, t.line, t.text)
+		}
+	}
+	return nil
+}
+
+// last returns the final value given for a key in a block
+func last(params map[string][]string, key string) string {
+	values := params[key]
+	if len(values) == 0 {
+		return ""
+	}
+	return values[len(values)-1]
+}
+
+func newAuthBlock(name string, params map[string][]string, dir string) (authBlock, error) {
+	block := authBlock{
+		name:            name,
+		hosts:           last(params, "hosts"),
+		defaultIdentity: last(params, "default"),
+		defaultDomain:   last(params, "default-domain"),
+		key:             last(params, "key"),
+	}
+
+	// Keys that would restrict which clients the block applies to are refused rather than ignored,
+	// otherwise the block would grant access more broadly than the configuration intends
+	for _, k := range []string{"localaddress", "res", "require_ssl", "perl_auth", "python_auth"} {
+		if _, ok := params[k]; ok {
+			return block, fmt.Errorf("auth block %q: %s is not supported", name, k)
+		}
+	}
+
+	for _, program := range params["auth"] {
+		fields := strings.Fields(program)
+		if len(fields) == 0 || filepath.Base(fields[0]) != "ckpasswd" {
+			return block, fmt.Errorf("auth block %q: unsupported auth program %q", name, program)
+		}
+		found := false
+		for j := 1; j+1 < len(fields); j++ {
+			if fields[j] == "-f" {
+				path := fields[j+1]
+				if !filepath.IsAbs(path) {
+					path = filepath.Join(dir, path)
+				}
+				block.passwordFiles = append(block.passwordFiles, path)
+				found = true
+			}
+		}
+		if !found {
+			return block, fmt.Errorf("auth block %q: ckpasswd is only supported with a password file given by -f", name)
+		}
+	}
+	return block, nil
+}
+
+// poisonToNegation rewrites INN's poison patterns, prefixed with '@', as negated wildmat patterns.
+// Refusing a post when any of its groups are not permitted has the same effect as poisoning
+func poisonToNegation(wildmat string) string {
+	patterns := strings.Split(wildmat, ",")
+	for i, p := range patterns {
+		if strings.HasPrefix(p, "@") {
+			patterns[i] = "!" + p[1:]
+		}
+	}
+	return strings.Join(patterns, ",")
+}
+
+func newAccessBlock(name string, params map[string][]string) accessBlock {
+	block := accessBlock{
+		name:  name,
+		users: last(params, "users"),
+		key:   last(params, "key"),
+		read:  "*",
+		post:  "*",
+
+		newnews: true,
+	}
+	if groups, ok := params["newsgroups"]; ok {
+		block.read = groups[len(groups)-1]
+		block.post = groups[len(groups)-1]
+	}
+	if read, ok := params["read"]; ok {
+		block.read = read[len(read)-1]
+	}
+	if post, ok := params["post"]; ok {
+		block.post = post[len(post)-1]
+	}
+	block.read = poisonToNegation(block.read)
+	block.post = poisonToNegation(block.post)
+	if access, ok := params["access"]; ok {
+		flags := access[len(access)-1]
+		if !strings.ContainsAny(flags, "Rr") {
+			block.read = ""
+		}
+		if !strings.ContainsAny(flags, "Pp") {
+			block.post = ""
+		}
+		block.newnews = strings.ContainsAny(flags, "Nn")
+	}
+	return block
+}
+
+// clientIP returns the IP address of a client, or nil if its address isn't an IP address
+func clientIP(addr net.Addr) net.IP {
+	if a, ok := addr.(*net.TCPAddr); ok {
+		return a.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return net.ParseIP(host)
+}
+
+// matchHosts reports whether a client address matches a hosts pattern list. Like a wildmat the patterns are
+// evaluated from right to left, and each may be an address wildmat, a CIDR block or a host name wildmat
+func (r *ReadersConf) matchHosts(hosts string, addr net.Addr) bool {
+	if hosts == "" {
+		return true
+	}
+
+	ip := clientIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	var names []string
+	resolved := false
+	patterns := strings.Split(hosts, ",")
+	for i := len(patterns) - 1; i >= 0; i-- {
+		pattern := strings.TrimSpace(patterns[i])
+		negated := strings.HasPrefix(pattern, "!")
+		pattern = strings.ToLower(strings.TrimPrefix(pattern, "!"))
+
+		matched := false
+		if strings.Contains(pattern, "/") {
+			if _, block, err := net.ParseCIDR(pattern); err == nil {
+				matched = block.Contains(ip)
+			}
+		} else if nntp.MatchWildmat(pattern, ip.String()) {
+			matched = true
+		} else if strings.ContainsAny(pattern, "abcdefghijklmnopqrstuvwxyz") {
+			if !resolved && r.LookupAddr != nil {
+				names, _ = r.LookupAddr(ip.String())
+				resolved = true
+			}
+			for _, n := range names {
+				if nntp.MatchWildmat(pattern, strings.ToLower(strings.TrimSuffix(n, "."))) {
+					matched = true
+					break
+				}
+			}
+		}
+		if matched {
+			return !negated
+		}
+	}
+	return false
+}
+
+// lookupPassword returns the crypt(3) hash recorded for a user in a passwd style file
+func lookupPassword(path string, username string) (string, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", false, err
+	}
+	defer f.Close()
+
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.SplitN(s.Text(), ":", 3)
+		if len(fields) >= 2 && fields[0] == username {
+			return fields[1], true, nil
+		}
+	}
+	return "", false, s.Err()
+}
+
+// passwordBlock returns the last auth block matching the client's address whose password files contain the user
+// name, along with the user's password hash. Authenticate and Access both select the auth block this way
+func (r *ReadersConf) passwordBlock(username string, addr net.Addr) (*authBlock, string, error) {
+	for i := len(r.authBlocks) - 1; i >= 0; i-- {
+		block := &r.authBlocks[i]
+		if !r.matchHosts(block.hosts, addr) {
+			continue
+		}
+		for _, path := range block.passwordFiles {
+			hashed, ok, err := lookupPassword(path, username)
+			if err != nil {
+				return nil, "", err
+			}
+			if ok {
+				return block, hashed, nil
+			}
+		}
+	}
+	return nil, "", nil
+}
+
+// identify determines the identity and key of a client. Anonymous clients are given the default identity of the
+// last auth block matching their address, while authenticated clients are identified by the auth block that accepted
+// their credentials, which is found again rather than remembered so that no state is kept between connections
+func (r *ReadersConf) identify(identity string, addr net.Addr) (string, string, bool) {
+	if identity != "" {
+		block, _, err := r.passwordBlock(identity, addr)
+		if err != nil || block == nil {
+			return "", "", false
+		}
+		return block.identity(identity), block.key, true
+	}
+	for i := len(r.authBlocks) - 1; i >= 0; i-- {
+		block := r.authBlocks[i]
+		if r.matchHosts(block.hosts, addr) && block.defaultIdentity != "" {
+			return block.defaultIdentity, block.key, true
+		}
+	}
+	return "", "", false
+}
+
+// accessFor returns the last access block applying to an identity given the key of the auth block that identified it
+func (r *ReadersConf) accessFor(identity string, key string) *accessBlock {
+	for i := len(r.accessBlocks) - 1; i >= 0; i-- {
+		block := &r.accessBlocks[i]
+		if block.key != "" && block.key != key {
+			continue
+		}
+		if block.users == "" || nntp.MatchWildmat(block.users, identity) {
+			return block
+		}
+	}
+	return nil
+}
+
+// AnonymousPostingAllowed reports whether any client can be given permission to post without authenticating
+func (r *ReadersConf) AnonymousPostingAllowed() bool {
+	for _, auth := range r.authBlocks {
+		if auth.defaultIdentity == "" {
+			continue
+		}
+		if access := r.accessFor(auth.defaultIdentity, auth.key); access != nil && access.post != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// FeedAllowed always returns false, peers are configured in INN's incoming.conf rather than readers.conf
+func (r *ReadersConf) FeedAllowed(net.Addr) bool {
+	return false
+}
+
+// HostAllowed reports whether any auth block applies to the client's address,
+// clients that no auth block matches are refused service like INN does
+func (r *ReadersConf) HostAllowed(addr net.Addr) bool {
+	for _, block := range r.authBlocks {
+		if r.matchHosts(block.hosts, addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticate checks credentials against the password files of the auth block identifying the client,
+// Access grants the permissions of the same block
+func (r *ReadersConf) Authenticate(username string, password string, addr net.Addr) (bool, error) {
+	block, hashed, err := r.passwordBlock(username, addr)
+	if err != nil || block == nil {
+		return false, err
+	}
+	return checkPassword(hashed, password)
+}
+
+// Access returns the permissions granted by the access block matching the client,
+// clients that no auth or access block applies to are denied access to every group
+func (r *ReadersConf) Access(identity string, addr net.Addr) nntp.ACL {
+	identity, key, ok := r.identify(identity, addr)
+	if !ok {
+		return nntp.ACL{}
+	}
+	access := r.accessFor(identity, key)
+	if access == nil {
+		return nntp.ACL{}
+	}
+
+	acl := nntp.ACL{}
+	if access.read != "" {
+		p := nntp.PermissionSee | nntp.PermissionRead
+		if access.newnews {
+			p |= nntp.PermissionNewnews
+		}
+		acl = append(acl, nntp.AccessRule{Groups: access.read, Permissions: p})
+	}
+	if access.post != "" {
+		acl = append(acl, nntp.AccessRule{Groups: access.post, Permissions: nntp.PermissionSee | nntp.PermissionPost})
+	}
+	return acl
+}
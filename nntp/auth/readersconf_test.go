@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Chemiseblanc/gonews/nntp"
+)
+
+// md5Secret is the MD5 crypt(3) hash of the password "secret"
+const md5Secret = "$1$saltstri$WHZcnT3IOdSrMvizOq7Ht1"
+
+// writeConf writes a readers.conf and the given password files into a temporary directory and loads it
+func writeConf(t *testing.T, conf string, passwd map[string]string) *ReadersConf {
+	t.Helper()
+	dir := t.TempDir()
+
+	for name, content := range passwd {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := filepath.Join(dir, "readers.conf")
+	if err := os.WriteFile(path, []byte(conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r, err := LoadReadersConf(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.LookupAddr = nil
+	return r
+}
+
+func addr(ip string) net.Addr {
+	return &net.TCPAddr{IP: net.ParseIP(ip), Port: 119}
+}
+
+func TestReadersConfAuthBlockSelection(t *testing.T) {
+	// Both auth blocks match the client and list the user, with different keys and password hashes
+	r := writeConf(t, `
+auth "everyone" {
+	hosts: "*"
+	auth: "ckpasswd -f all.passwd"
+	key: "public"
+}
+auth "local" {
+	hosts: "10.0.0.0/8"
+	auth: "ckpasswd -f local.passwd"
+	key: "staff"
+	default-domain: "example.com"
+}
+access "public" {
+	users: "*"
+	key: "public"
+	newsgroups: "misc.*"
+	access: "R"
+}
+access "staff" {
+	users: "*@example.com"
+	key: "staff"
+	newsgroups: "*"
+	access: "RPN"
+}
+`, map[string]string{
+		"all.passwd":   "alice:$1$saltstri$badhashbadhashbadhash.\n",
+		"local.passwd": "alice:" + md5Secret + "\n",
+	})
+
+	// The last matching block decides, so the password in the first block no longer works from the local network
+	ok, err := r.Authenticate("alice", "secret", addr("10.1.2.3"))
+	if err != nil || !ok {
+		t.Fatalf("Authenticate = %v, %v, want the last matching auth block to accept the password", ok, err)
+	}
+	acl := r.Access("alice", addr("10.1.2.3"))
+	if !acl.Allowed("comp.lang.go", nntp.PermissionRead|nntp.PermissionPost|nntp.PermissionNewnews) {
+		t.Errorf("access from the staff block not granted, got %v", acl)
+	}
+
+	// From elsewhere only the first block matches, whose hash doesn't match the password
+	if ok, _ := r.Authenticate("alice", "secret", addr("192.0.2.1")); ok {
+		t.Error("Authenticate accepted a password from an auth block that doesn't match the client")
+	}
+	// A user who authenticated there would be granted the access of the first block
+	acl = r.Access("alice", addr("192.0.2.1"))
+	if !acl.Allowed("misc.test", nntp.PermissionRead) || acl.Allowed("comp.lang.go", nntp.PermissionRead) {
+		t.Errorf("access from the public block not granted, got %v", acl)
+	}
+}
+
+func TestReadersConfAnonymous(t *testing.T) {
+	r := writeConf(t, `
+auth "anonymous" {
+	hosts: "*, !192.0.2.*"
+	default: "<guest>"
+}
+access "guest" {
+	users: "<guest>"
+	read: "*,!secret.*"
+	post: "misc.test"
+}
+`, nil)
+
+	if !r.HostAllowed(addr("198.51.100.1")) || r.HostAllowed(addr("192.0.2.1")) {
+		t.Error("hosts pattern not applied to HostAllowed")
+	}
+	acl := r.Access("", addr("198.51.100.1"))
+	if !acl.Allowed("comp.lang.go", nntp.PermissionRead) || acl.Allowed("secret.plans", nntp.PermissionSee) {
+		t.Errorf("read patterns not applied, got %v", acl)
+	}
+	if !acl.Allowed("misc.test", nntp.PermissionRead|nntp.PermissionPost) || acl.Allowed("comp.lang.go", nntp.PermissionPost) {
+		t.Errorf("post patterns not applied, got %v", acl)
+	}
+	if !r.AnonymousPostingAllowed() {
+		t.Error("AnonymousPostingAllowed = false, want true")
+	}
+	if acl := r.Access("", addr("192.0.2.1")); acl.Allowed("comp.lang.go", nntp.PermissionSee) {
+		t.Errorf("client matched by no auth block granted %v", acl)
+	}
+}
+
+func TestParseReadersConfErrors(t *testing.T) {
+	tests := []string{
+		`auth "a" {`,
+		`auth "a" { hosts "*" }`,
+		`auth "a" { auth: "radius" }`,
+		`auth "a" { auth: "ckpasswd -s" }`,
+		`auth "a" { require_ssl: true }`,
+		`access "a" { users: "*" } }`,
+		`auth "a" { hosts: "*`,
+		`include`,
+	}
+	for _, conf := range tests {
+		if _, err := ParseReadersConf(strings.NewReader(conf)); err == nil {
+			t.Errorf("ParseReadersConf(%q) succeeded, want an error", conf)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package nntp
+
+// Implementation is advertised in the IMPLEMENTATION capability
+const Implementation = "gonews"
+
+// commandAvailable reports whether the server has registered a command and the client is currently able to use it
+func commandAvailable(c *Conn, name string) bool {
+	cmd, ok := c.server.LookupCommand(name)
+	return ok && cmd.available(c)
+}
+
+// feedAllowed reports whether the client is allowed to feed articles to the server
+func feedAllowed(c *Conn) bool {
+	auth := c.AuthBackend()
+	return auth != nil && auth.FeedAllowed(c.Context(), c.RemoteAddr())
+}
+
+// capabilities returns the capabilities advertised to the client as described in section 5.2 of RFC3977. They are
+// derived from the commands registered on the server, the storage and authentication backends, and the connection's
+// mode, TLS and authentication state, so the list changes as the client switches modes, starts TLS or logs in
+func capabilities(c *Conn) []string {
+	caps := []string{"VERSION 2", "IMPLEMENTATION " + Implementation}
+	s := c.StorageBackend()
+
+	if c.mode&ModeReader != 0 {
+		if commandAvailable(c, "ARTICLE") {
+			caps = append(caps, "READER")
+		}
+		if _, ok := overviewStorage(s); ok && commandAvailable(c, "OVER") {
+			caps = append(caps, "OVER MSGID")
+		}
+		if _, ok := arrivalStorage(s); ok && commandAvailable(c, "NEWNEWS") &&
+			c.Access().Grants(PermissionSee|PermissionRead|PermissionNewnews) {
+			caps = append(caps, "NEWNEWS")
+		}
+		if commandAvailable(c, "POST") && postingAllowed(c) {
+			caps = append(caps, "POST")
+		}
+	} else if commandAvailable(c, "MODE") {
+		caps = append(caps, "MODE-READER")
+	}
+
+	if c.mode&ModeTransit != 0 && feedAllowed(c) {
+		if commandAvailable(c, "IHAVE") {
+			caps = append(caps, "IHAVE")
+		}
+		if commandAvailable(c, "MODE") && commandAvailable(c, "CHECK") && commandAvailable(c, "TAKETHIS") {
+			caps = append(caps, "STREAMING")
+		}
+	}
+
+	if commandAvailable(c, "LIST") {
+		caps = append(caps, listCapability(s))
+	}
+
+	// STARTTLS can't be used once the connection is secured or the client has authenticated, as described in
+	// section 2.2.2 of RFC4642, and AUTHINFO is withdrawn after authentication as described in section 2.1 of RFC4643
+	if c.server.TLSConfig != nil && !c.isTLS && c.identity == "" && commandAvailable(c, "STARTTLS") {
+		caps = append(caps, "STARTTLS")
+	}
+	if c.identity == "" && c.AuthBackend() != nil && commandAvailable(c, "AUTHINFO") {
+		if c.server.RequireTLS && !c.isTLS {
+			caps = append(caps, "AUTHINFO")
+		} else {
+			caps = append(caps, "AUTHINFO USER SASL", "SASL PLAIN")
+		}
+	}
+
+	for _, cmd := range c.server.Commands() {
+		if cmd.Capability != "" && cmd.available(c) && !hasCapability(caps, cmd.Capability) {
+			caps = append(caps, cmd.Capability)
+		}
+	}
+	return caps
+}
+
+// hasCapability reports whether a capability has already been advertised
+func hasCapability(caps []string, capability string) bool {
+	for _, v := range caps {
+		if v == capability {
+			return true
+		}
+	}
+	return false
+}
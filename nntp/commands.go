@@ -2,11 +2,86 @@ package nntp
 
 import (
 	"bufio"
+	"bytes"
 	"crypto/tls"
+	"encoding/base64"
+	"io"
 	"net"
+	"net/textproto"
 	"strconv"
+	"strings"
+	"time"
 )
 
+// parseRange parses an article range of the form "n", "n-" or "n-m" as described in section 3.1 of RFC3977,
+// an open ended range extends to the high water mark of the group
+func parseRange(arg string, g Group) (uint, uint, bool) {
+	parts := strings.SplitN(arg, "-", 2)
+	low, err := strconv.ParseUint(parts[0], 10, 0)
+	if err != nil {
+		return 0, 0, false
+	}
+	if len(parts) == 1 {
+		return uint(low), uint(low), true
+	}
+	if parts[1] == "" {
+		return uint(low), g.Max, true
+	}
+	high, err := strconv.ParseUint(parts[1], 10, 0)
+	if err != nil {
+		return 0, 0, false
+	}
+	return uint(low), uint(high), true
+}
+
+// parseDateTime parses the date and time arguments of NEWGROUPS and NEWNEWS as described in section 7.3.2 of RFC3977.
+// The time is in the server's local time zone unless gmt is true, and two digit years are taken from the current
+// century if they are not after the current year, or the previous century otherwise
+func parseDateTime(date string, t string, gmt bool, now time.Time) (time.Time, bool) {
+	if (len(date) != 6 && len(date) != 8) || len(t) != 6 {
+		return time.Time{}, false
+	}
+	for _, ch := range date + t {
+		if ch < '0' || ch > '9' {
+			return time.Time{}, false
+		}
+	}
+
+	loc := time.Local
+	if gmt {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+
+	var year int
+	if len(date) == 8 {
+		year, _ = strconv.Atoi(date[:4])
+		date = date[4:]
+	} else {
+		year, _ = strconv.Atoi(date[:2])
+		date = date[2:]
+		century := now.Year() / 100 * 100
+		if year <= now.Year()%100 {
+			year += century
+		} else {
+			year += century - 100
+		}
+	}
+	month, _ := strconv.Atoi(date[:2])
+	day, _ := strconv.Atoi(date[2:])
+	hour, _ := strconv.Atoi(t[:2])
+	minute, _ := strconv.Atoi(t[2:4])
+	second, _ := strconv.Atoi(t[4:])
+
+	parsed := time.Date(year, time.Month(month), day, hour, minute, second, 0, loc)
+	// time.Date normalizes out of range values, so they are rejected by checking nothing was carried over
+	if parsed.Month() != time.Month(month) || parsed.Day() != day || parsed.Hour() != hour ||
+		parsed.Minute() != minute || parsed.Second() != second {
+		return time.Time{}, false
+	}
+	return parsed, true
+}
+
 // isMessageID is a helper function for checking if a given argument refers to an article number of message-id
 func isMessageID(identifier string) bool {
 	if len(identifier) > 0 && identifier[0] == '<' {
@@ -16,6 +91,15 @@ func isMessageID(identifier string) bool {
 	}
 }
 
+// writeAccessDenied responds to a command the client isn't permitted to use,
+// asking it to authenticate first if doing so could grant it access
+func writeAccessDenied(c *Conn) error {
+	if c.identity == "" {
+		return c.WriteLine(ResponseText(ResponseAuthRequired))
+	}
+	return c.WriteLine(ResponseText(ResponsePermissionDenied))
+}
+
 // retrievalHandler is a unified implementation of the shared behaviour of ARTICLE, HEAD, and BODY commands
 func retrievalHandler(c *Conn, args []string, responseHandler func(*Conn, uint, *Article) error) error {
 	if len(args) > 1 {
@@ -32,12 +116,12 @@ func retrievalHandler(c *Conn, args []string, responseHandler func(*Conn, uint,
 		ident := args[0]
 		if isMessageID(ident) {
 			// Article specified by Message-ID
-			article, err := s.ArticleByID(ident)
+			article, err := s.ArticleByID(c.Context(), ident)
 			if err != nil {
 				return err
 			}
 
-			if article != nil {
+			if article != nil && articleReadable(c, article) {
 				return responseHandler(c, 0, article)
 			} else {
 				return c.WriteLine(ResponseText(ResponseArticleNotFound))
@@ -48,19 +132,20 @@ func retrievalHandler(c *Conn, args []string, responseHandler func(*Conn, uint,
 			if g != nil {
 				article_number, err := strconv.ParseUint(ident, 10, 0)
 				if err != nil {
-					return err
+					return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
 				}
 
-				article, err := s.ArticleByGroup(*g, uint(article_number))
+				article, err := s.ArticleByGroup(c.Context(), *g, uint(article_number))
 				if err != nil {
 					return err
 				}
 
 				if article != nil {
-					*c.articleNumber = uint(article_number)
-					return responseHandler(c, uint(article_number), article)
+					number := uint(article_number)
+					c.articleNumber = &number
+					return responseHandler(c, number, article)
 				} else {
-					return c.WriteLine(ResponseText(ResponseArticleNotFound))
+					return c.WriteLine(ResponseText(ResponseArticleNotInGroup))
 				}
 			} else {
 				return c.WriteLine(ResponseText(ResponseGroupNotSelected))
@@ -92,6 +177,100 @@ func ArticleHander(c *Conn, args []string) error {
 	})
 }
 
+// Implements the AUTHINFO command as described in section 2 of RFC4643
+func AuthinfoHandler(c *Conn, args []string) error {
+	if len(args) < 1 {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+
+	if c.identity != "" {
+		return c.WriteLine(ResponseText(ResponsePermissionDenied))
+	}
+	if c.server.RequireTLS && !c.isTLS {
+		return c.WriteLine(ResponseText(ResponseEncryptionRequired))
+	}
+	auth := c.AuthBackend()
+	if auth == nil {
+		return c.WriteLine(ResponseText(ResponseCommandNotSupported))
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "USER":
+		if len(args) != 2 {
+			return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+		}
+		c.pendingUser = args[1]
+		return c.WriteLine(ResponseText(ResponsePasswordRequired))
+	case "PASS":
+		if len(args) != 2 {
+			return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+		}
+		if c.pendingUser == "" {
+			return c.WriteLine(ResponseText(ResponseAuthOutOfSequence))
+		}
+		username := c.pendingUser
+		c.pendingUser = ""
+		return authenticate(c, username, args[1])
+	case "SASL":
+		if len(args) < 2 || len(args) > 3 {
+			return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+		}
+		if strings.ToUpper(args[1]) != "PLAIN" {
+			return c.WriteLine(ResponseText(ResponseCommandNotSupported))
+		}
+
+		var response string
+		if len(args) == 3 {
+			response = args[2]
+		} else {
+			// PLAIN has no server challenge, so an empty one is sent to ask for the initial response
+			if err := c.WriteLine(ResponseText(ResponseSASLChallenge, "=")); err != nil {
+				return err
+			}
+			line, err := c.ReadLine()
+			if err != nil {
+				return err
+			}
+			if line == "*" {
+				return c.WriteLine(ResponseText(ResponseAuthFailed))
+			}
+			response = line
+		}
+		if response == "=" {
+			response = ""
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(response)
+		if err != nil {
+			return c.WriteLine(ResponseText(ResponseBase64Error))
+		}
+		// The PLAIN message is authzid NUL authcid NUL passwd as described in section 2 of RFC4616
+		fields := strings.Split(string(decoded), "\x00")
+		if len(fields) != 3 || fields[1] == "" || (fields[0] != "" && fields[0] != fields[1]) {
+			return c.WriteLine(ResponseText(ResponseAuthFailed))
+		}
+		return authenticate(c, fields[1], fields[2])
+	default:
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+}
+
+// authenticate checks credentials given by AUTHINFO against the authentication backend
+// and records the identity of the client if they are accepted
+func authenticate(c *Conn, username string, password string) error {
+	ok, err := c.AuthBackend().Authenticate(c.Context(), username, password, c.RemoteAddr())
+	if err != nil {
+		c.server.logf("authenticating %s from %s: %v", username, c.RemoteAddr(), err)
+		return c.WriteLine(ResponseText(ResponseInternalFault))
+	}
+	if !ok {
+		return c.WriteLine(ResponseText(ResponseAuthFailed))
+	}
+	c.identity = username
+	c.aclLoaded = false
+	return c.WriteLine(ResponseText(ResponseAuthAccepted))
+}
+
 // Implements the BODY command as described in section 6.2.3 of RFC3977
 func BodyHandler(c *Conn, args []string) error {
 	return retrievalHandler(c, args, func(c *Conn, number uint, a *Article) error {
@@ -104,33 +283,10 @@ func BodyHandler(c *Conn, args []string) error {
 
 // Implements the CAPABILITIES command as described in section 5.2 of RFC3977
 func CapabilitiesHandler(c *Conn, args []string) error {
-	caps := []string{
-		"VERSION 2",
-		"BODY",
-		"CAPABILITIES",
-		"DATE",
-		"GROUP",
-		"HDR",
-		"HEAD",
-		"HELP",
-		"IHAVE",
-		"LAST",
-		"LIST",
-		"LISTGROUP",
-		"MODE",
-		"NEWGROUPS",
-		"NEWNEWS",
-		"NEXT",
-		"OVER",
-		"POST",
-		"STAT",
-		"QUIT",
-		"STARTTLS",
-	}
 	if err := c.WriteLine(ResponseText(ResponseCapabilitiesFollows)); err != nil {
 		return err
 	}
-	for _, v := range caps {
+	for _, v := range capabilities(c) {
 		if err := c.WriteLine(v); err != nil {
 			return err
 		}
@@ -140,7 +296,10 @@ func CapabilitiesHandler(c *Conn, args []string) error {
 
 // Implements the DATE command as described in section 7.1 of RFC3977
 func DateHandler(c *Conn, args []string) error {
-	return nil
+	if len(args) != 0 {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+	return c.WriteLine(ResponseText(ResponseServerDate, c.server.now().UTC().Format("20060102150405")))
 }
 
 // Implements the GROUP command as described in section 6.1.1 of RFC3977
@@ -152,25 +311,189 @@ func GroupHandler(c *Conn, args []string) error {
 		return nil
 	}
 
+	g, err := selectGroup(c, args[0])
+	if g == nil || err != nil {
+		return err
+	}
+	return c.WriteLine(ResponseText(ResponseGroupSelected, g.Count, g.Min, g.Max, g.Name))
+}
+
+// selectGroup is a unified implementation of the shared behaviour of the GROUP and LISTGROUP commands. It makes the
+// named group the current group and its first article the current article, if the group can't be selected an error
+// response is sent and nil is returned
+func selectGroup(c *Conn, name string) (*Group, error) {
+	g := c.StorageBackend().Group(c.Context(), name)
+	if g == nil || !c.Access().Allowed(g.Name, PermissionSee) {
+		return nil, c.WriteLine(ResponseText(ResponseGroupNotFound))
+	}
+	if !c.Access().Allowed(g.Name, PermissionRead) {
+		return nil, writeAccessDenied(c)
+	}
+
+	c.group = g
+	c.articleNumber = nil
+	if g.Count > 0 {
+		number := g.Min
+		c.articleNumber = &number
+	}
+	return g, nil
+}
+
+// headerValue returns the value of a header or metadata item of an article read from the storage backend
+func headerValue(a *Article, field string) (string, error) {
+	if !strings.HasPrefix(field, ":") {
+		return strings.Join(a.MIMEHeader.Values(field), " "), nil
+	}
+	body, err := io.ReadAll(a.Body)
+	if err != nil {
+		return "", err
+	}
+	value, _ := NewOverview(0, a.MIMEHeader, body).Field(field)
+	return value, nil
+}
+
+// Implements the HDR command as described in section 8.5 of RFC3977
+func HdrHandler(c *Conn, args []string) error {
+	return hdrHandler(c, args, false)
+}
+
+// Implements the XHDR command as described in section 2.6 of RFC2980, which differs from HDR in its response code
+// and by identifying the article requested by message-id in the response rather than with 0
+func XhdrHandler(c *Conn, args []string) error {
+	return hdrHandler(c, args, true)
+}
+
+// hdrHandler is the shared implementation of HDR and XHDR
+func hdrHandler(c *Conn, args []string, xhdr bool) error {
+	if len(args) < 1 || len(args) > 2 {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+
+	field := args[0]
+	if strings.HasPrefix(field, ":") && !strings.EqualFold(field, ":bytes") && !strings.EqualFold(field, ":lines") {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+	field = strings.ToLower(field)
+
 	s := c.StorageBackend()
-	g := s.Group(args[0])
+	ovs, fastPath := overviewStorage(s)
+	fastPath = fastPath && overviewHasField(ovs.OverviewFormat(c.Context()), field)
 
-	if g != nil {
-		c.group = g
-		if err := c.WriteLine(ResponseText(ResponseGroupSelected, g.Min, g.Max, g.Count, g.Name)); err != nil {
-			return err
+	type header struct {
+		id    string
+		value string
+	}
+	var headers []header
+
+	if len(args) == 2 && isMessageID(args[1]) {
+		// First form of HDR command
+		id := "0"
+		if xhdr {
+			id = args[1]
+		}
+		if fastPath {
+			ov, err := ovs.OverviewByID(c.Context(), args[1])
+			if err != nil {
+				return err
+			}
+			if ov != nil {
+				if readable, err := overviewReadable(c, ov); err != nil {
+					return err
+				} else if !readable {
+					ov = nil
+				}
+			}
+			if ov == nil {
+				return c.WriteLine(ResponseText(ResponseArticleNotFound))
+			}
+			value, _ := ov.Field(field)
+			headers = append(headers, header{id, value})
+		} else {
+			a, err := s.ArticleByID(c.Context(), args[1])
+			if err != nil {
+				return err
+			}
+			if a == nil || !articleReadable(c, a) {
+				return c.WriteLine(ResponseText(ResponseArticleNotFound))
+			}
+			value, err := headerValue(a, field)
+			if err != nil {
+				return err
+			}
+			headers = append(headers, header{id, value})
 		}
 	} else {
-		if err := c.WriteLine(ResponseText(ResponseGroupNotFound)); err != nil {
-			return err
+		g := c.group
+		if g == nil {
+			return c.WriteLine(ResponseText(ResponseGroupNotSelected))
+		}
+
+		var low, high uint
+		if len(args) == 2 {
+			// Second form of HDR command
+			var ok bool
+			if low, high, ok = parseRange(args[1], *g); !ok {
+				return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+			}
+		} else {
+			// Third form of HDR command
+			if c.articleNumber == nil {
+				return c.WriteLine(ResponseText(ResponseArticleNotSelected))
+			}
+			low, high = *c.articleNumber, *c.articleNumber
+		}
+
+		if fastPath {
+			overviews, err := ovs.OverviewByGroup(c.Context(), *g, low, high)
+			if err != nil {
+				return err
+			}
+			for _, ov := range overviews {
+				value, _ := ov.Field(field)
+				headers = append(headers, header{strconv.FormatUint(uint64(ov.Number), 10), value})
+			}
+		} else {
+			numbers, err := s.ArticleNumbers(c.Context(), *g, low, high)
+			if err != nil {
+				return err
+			}
+			for _, number := range numbers {
+				a, err := s.ArticleByGroup(c.Context(), *g, number)
+				if err != nil {
+					return err
+				}
+				if a == nil {
+					continue
+				}
+				value, err := headerValue(a, field)
+				if err != nil {
+					return err
+				}
+				headers = append(headers, header{strconv.FormatUint(uint64(number), 10), value})
+			}
+		}
+
+		if len(headers) == 0 {
+			if len(args) == 1 {
+				return c.WriteLine(ResponseText(ResponseArticleNotSelected))
+			}
+			return c.WriteLine(ResponseText(ResponseArticleNotInGroup))
 		}
 	}
-	return nil
-}
 
-// Implements the HDR command as described in section 8.5 of RFC3977
-func HdrHandler(c *Conn, args []string) error {
-	return nil
+	status := ResponseText(ResponseHeadersFollow)
+	if xhdr {
+		status = xhdrResponseText()
+	}
+	if err := c.WriteLine(status); err != nil {
+		return err
+	}
+	for _, h := range headers {
+		if err := c.WriteLine("%s %s", h.id, overviewEscaper.Replace(h.value)); err != nil {
+			return err
+		}
+	}
+	return c.WriteLine(".")
 }
 
 // Implements the HEAD command as described in section 6.2.2 of RFC3977
@@ -185,12 +508,148 @@ func HeadHandler(c *Conn, args []string) error {
 
 // Implements the HELP command as described in section 7.2 of RFC3977
 func HelpHandler(c *Conn, args []string) error {
-	return nil
+	if len(args) != 0 {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+
+	if err := c.WriteLine(ResponseText(ResponseHelpFollows)); err != nil {
+		return err
+	}
+	for _, cmd := range c.server.Commands() {
+		if !cmd.available(c) {
+			continue
+		}
+		help := cmd.Help
+		if help == "" {
+			help = cmd.Name
+		}
+		if err := c.WriteLine("  %s", help); err != nil {
+			return err
+		}
+	}
+	return c.WriteLine(".")
+}
+
+// transferResult is the outcome of storing an article offered by a peer
+type transferResult int
+
+const (
+	transferAccepted transferResult = iota
+	transferRejected
+	transferDeferred
+)
+
+// transferArticle is a unified implementation of the shared behaviour of the IHAVE and TAKETHIS commands
+// for running an offered article through the message filter and into the storage backend
+func transferArticle(c *Conn, id string, article *Article) transferResult {
+	if article.MessageID() != id {
+		return transferRejected
+	}
+	if f := c.MessageFilter(); f != nil && f(*article) {
+		return transferRejected
+	}
+	if err := c.StorageBackend().PostArticle(c.Context(), *article); err != nil {
+		return transferDeferred
+	}
+	return transferAccepted
+}
+
+// Implements the CHECK command as described in section 2.4 of RFC4644
+func CheckHandler(c *Conn, args []string) error {
+	if len(args) != 1 || !isMessageID(args[0]) {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+
+	if auth := c.AuthBackend(); auth == nil || !auth.FeedAllowed(c.Context(), c.RemoteAddr()) {
+		return c.WriteLine(ResponseText(ResponsePermissionDenied))
+	}
+
+	id := args[0]
+	if c.StorageBackend().HasArticle(c.Context(), id) {
+		return c.WriteLine(ResponseText(ResponseArticleNotWantedStream, id))
+	}
+	if c.server.transfers.has(id) {
+		return c.WriteLine(ResponseText(ResponseArticleTryLater, id))
+	}
+	return c.WriteLine(ResponseText(ResponseSendArticleStream, id))
 }
 
 // Implements the IHAVE command as described in section 6.3.2 of RFC3977
 func IhaveHandler(c *Conn, args []string) error {
-	return nil
+	if len(args) != 1 || !isMessageID(args[0]) {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+
+	if auth := c.AuthBackend(); auth == nil || !auth.FeedAllowed(c.Context(), c.RemoteAddr()) {
+		return c.WriteLine(ResponseText(ResponsePermissionDenied))
+	}
+
+	id := args[0]
+	if c.StorageBackend().HasArticle(c.Context(), id) {
+		return c.WriteLine(ResponseText(ResponseArticleNotWanted))
+	}
+	if !c.server.transfers.claim(id) {
+		return c.WriteLine(ResponseText(ResponseArticleTransferFailed))
+	}
+	defer c.server.transfers.release(id)
+
+	if err := c.WriteLine(ResponseText(ResponseTransferArticle)); err != nil {
+		return err
+	}
+	article, err := c.ReadArticle()
+	if err != nil {
+		if _, ok := err.(net.Error); ok {
+			return err
+		}
+		// An article with a malformed header won't be any better when it is offered again
+		if _, ok := err.(textproto.ProtocolError); ok {
+			return c.WriteLine(ResponseText(ResponseArticleRejected))
+		}
+		return c.WriteLine(ResponseText(ResponseArticleTransferFailed))
+	}
+	// Make sure the rest of the article is consumed before responding regardless of the outcome
+	defer io.Copy(io.Discard, article.Body)
+
+	switch transferArticle(c, id, article) {
+	case transferAccepted:
+		return c.WriteLine(ResponseText(ResponseArticleTransferred))
+	case transferDeferred:
+		return c.WriteLine(ResponseText(ResponseArticleTransferFailed))
+	default:
+		return c.WriteLine(ResponseText(ResponseArticleRejected))
+	}
+}
+
+// Implements the TAKETHIS command as described in section 2.5 of RFC4644
+func TakethisHandler(c *Conn, args []string) error {
+	if len(args) != 1 || !isMessageID(args[0]) {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+
+	// The article follows the command immediately so it has to be read even if it is going to be rejected
+	article, err := c.ReadArticle()
+	if err != nil {
+		if _, ok := err.(net.Error); ok {
+			return err
+		}
+		return c.WriteLine(ResponseText(ResponseArticleRejectedStream, args[0]))
+	}
+	defer io.Copy(io.Discard, article.Body)
+
+	if auth := c.AuthBackend(); auth == nil || !auth.FeedAllowed(c.Context(), c.RemoteAddr()) {
+		return c.WriteLine(ResponseText(ResponsePermissionDenied))
+	}
+
+	id := args[0]
+	if c.StorageBackend().HasArticle(c.Context(), id) || !c.server.transfers.claim(id) {
+		return c.WriteLine(ResponseText(ResponseArticleRejectedStream, id))
+	}
+	defer c.server.transfers.release(id)
+
+	if transferArticle(c, id, article) == transferAccepted {
+		return c.WriteLine(ResponseText(ResponseArticleTransferredStream, id))
+	}
+	return c.WriteLine(ResponseText(ResponseArticleRejectedStream, id))
 }
 
 // Implements the LAST command as described in section 6.1.3 of RFC3977
@@ -202,14 +661,18 @@ func LastHandler(c *Conn, args []string) error {
 		return nil
 	}
 	if g := c.group; g != nil {
-		if *c.articleNumber >= g.Min {
+		if c.articleNumber == nil {
+			return c.WriteLine(ResponseText(ResponseArticleNotSelected))
+		}
+		if *c.articleNumber > g.Min {
 			s := c.StorageBackend()
-			for number := *c.articleNumber - 1; number >= g.Min; number-- {
-				if a, err := s.ArticleByGroup(*g, number); err != nil {
+			// Counting down to the number above the low water mark avoids wrapping around when it is zero
+			for number := *c.articleNumber; number > g.Min; number-- {
+				if a, err := s.ArticleByGroup(c.Context(), *g, number-1); err != nil {
 					return err
 				} else if a != nil {
-					*c.articleNumber = number
-					return c.WriteLine(ResponseText(ResponseArticleRetrieved, number, a.MessageID()))
+					*c.articleNumber = number - 1
+					return c.WriteLine(ResponseText(ResponseArticleRetrieved, number-1, a.MessageID()))
 				} else {
 					continue
 				}
@@ -225,27 +688,188 @@ func LastHandler(c *Conn, args []string) error {
 
 // Implements the LIST command as described in section 7.6.1 of RFC3977
 func ListHandler(c *Conn, args []string) error {
-	return nil
+	if len(args) > 2 {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+
+	keyword := "ACTIVE"
+	if len(args) > 0 {
+		keyword = strings.ToUpper(args[0])
+	}
+	list, ok := listKeywords[keyword]
+	if !ok {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+
+	var arg string
+	if len(args) == 2 {
+		arg = args[1]
+		if keyword == "HEADERS" {
+			arg = strings.ToUpper(arg)
+			if arg != "MSGID" && arg != "RANGE" {
+				return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+			}
+		} else if !list.wildmat {
+			return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+		}
+	}
+
+	if !list.supported(c.StorageBackend()) {
+		return c.WriteLine(ResponseText(ResponseCommandNotSupported))
+	}
+
+	lines, err := list.lines(c, arg)
+	if err != nil {
+		return err
+	}
+	if err := c.WriteLine(ResponseText(ResponseGroupListFollows)); err != nil {
+		return err
+	}
+	for _, line := range lines {
+		if err := c.WriteLine(line); err != nil {
+			return err
+		}
+	}
+	return c.WriteLine(".")
 }
 
 // Implements the LISTGROUP command as described in section 6.1.2 of RFC3977
 func ListgroupHandler(c *Conn, args []string) error {
-	return nil
+	if len(args) > 2 {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+
+	var name string
+	if len(args) > 0 {
+		name = args[0]
+	} else if c.group != nil {
+		name = c.group.Name
+	} else {
+		return c.WriteLine(ResponseText(ResponseGroupNotSelected))
+	}
+	g, err := selectGroup(c, name)
+	if g == nil || err != nil {
+		return err
+	}
+
+	low, high := g.Min, g.Max
+	if len(args) == 2 {
+		var ok bool
+		if low, high, ok = parseRange(args[1], *g); !ok {
+			return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+		}
+	}
+
+	numbers, err := c.StorageBackend().ArticleNumbers(c.Context(), *g, low, high)
+	if err != nil {
+		return err
+	}
+	if err := c.WriteLine(ResponseText(ResponseGroupSelected, g.Count, g.Min, g.Max, g.Name)); err != nil {
+		return err
+	}
+	for _, number := range numbers {
+		if err := c.WriteLine("%d", number); err != nil {
+			return err
+		}
+	}
+	return c.WriteLine(".")
 }
 
-// Implements the MODE command as described in section 5.3 of RFC3977
+// Implements the MODE READER command as described in section 5.3 of RFC3977 and MODE STREAM as described in section 2.3 of RFC4644
 func ModeHandler(c *Conn, args []string) error {
-	return nil
+	if len(args) != 1 {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+
+	switch strings.ToUpper(args[0]) {
+	case "READER":
+		c.mode = ModeReader
+		if !c.server.ModeSwitching {
+			c.mode = ModeAny
+		}
+		if postingAllowed(c) {
+			return c.WriteLine(ResponseText(ResponseServerReadyPosting))
+		}
+		return c.WriteLine(ResponseText(ResponseServerReadyNoPosting))
+	case "STREAM":
+		if c.mode&ModeTransit == 0 {
+			return c.WriteLine(ResponseText(ResponsePermissionDenied))
+		}
+		if auth := c.AuthBackend(); auth == nil || !auth.FeedAllowed(c.Context(), c.RemoteAddr()) {
+			return c.WriteLine(ResponseText(ResponsePermissionDenied))
+		}
+		return c.WriteLine(ResponseText(ResponseStreamingPermitted))
+	default:
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
 }
 
 // Implements the NEWGROUPS command as described in section 7.3 of RFC3977
 func NewgroupsHandler(c *Conn, args []string) error {
-	return nil
+	if len(args) < 2 || len(args) > 3 || (len(args) == 3 && args[2] != "GMT") {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+	since, ok := parseDateTime(args[0], args[1], len(args) == 3, c.server.now())
+	if !ok {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+
+	groups, err := matchingGroups(c, "")
+	if err != nil {
+		return err
+	}
+	if err := c.WriteLine(ResponseText(ResponseNewGroupsFollow)); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		if g.Created.IsZero() || g.Created.Before(since) {
+			continue
+		}
+		if err := c.WriteLine(activeLine(g)); err != nil {
+			return err
+		}
+	}
+	return c.WriteLine(".")
 }
 
 // Implements the NEWNEWS command as described in section 7.4 of RFC3977
 func NewnewsHandler(c *Conn, args []string) error {
-	return nil
+	if len(args) < 3 || len(args) > 4 || (len(args) == 4 && args[3] != "GMT") {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+	since, ok := parseDateTime(args[1], args[2], len(args) == 4, c.server.now())
+	if !ok {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+
+	acl := c.Access()
+	if !acl.Grants(PermissionSee | PermissionRead | PermissionNewnews) {
+		return writeAccessDenied(c)
+	}
+	s, ok := arrivalStorage(c.StorageBackend())
+	if !ok {
+		return c.WriteLine(ResponseText(ResponseCommandNotSupported))
+	}
+
+	arrivals, err := s.ArticlesSince(c.Context(), since)
+	if err != nil {
+		return err
+	}
+	if err := c.WriteLine(ResponseText(ResponseNewArticlesFollow)); err != nil {
+		return err
+	}
+	wildmat := args[0]
+	for _, a := range arrivals {
+		for _, g := range a.Newsgroups {
+			if MatchWildmat(wildmat, g) && acl.Allowed(g, PermissionSee|PermissionRead|PermissionNewnews) {
+				if err := c.WriteLine(a.MessageID); err != nil {
+					return err
+				}
+				break
+			}
+		}
+	}
+	return c.WriteLine(".")
 }
 
 // Implements the NEXT command as described in section 6.1.4 of RFC3977
@@ -257,10 +881,13 @@ func NextHandler(c *Conn, args []string) error {
 		return nil
 	}
 	if g := c.group; g != nil {
-		if *c.articleNumber <= g.Max {
+		if c.articleNumber == nil {
+			return c.WriteLine(ResponseText(ResponseArticleNotSelected))
+		}
+		if *c.articleNumber < g.Max {
 			s := c.StorageBackend()
 			for number := *c.articleNumber + 1; number <= g.Max; number++ {
-				if a, err := s.ArticleByGroup(*g, number); err != nil {
+				if a, err := s.ArticleByGroup(c.Context(), *g, number); err != nil {
 					return err
 				} else if a != nil {
 					*c.articleNumber = number
@@ -280,7 +907,77 @@ func NextHandler(c *Conn, args []string) error {
 
 // Implements the OVER command as described in section 8.3 of RFC3977
 func OverHandler(c *Conn, args []string) error {
-	return nil
+	if len(args) > 1 {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+
+	s, ok := overviewStorage(c.StorageBackend())
+	if !ok {
+		return c.WriteLine(ResponseText(ResponseCommandNotSupported))
+	}
+
+	var overviews []Overview
+	if len(args) == 1 && isMessageID(args[0]) {
+		// First form of OVER command
+		ov, err := s.OverviewByID(c.Context(), args[0])
+		if err != nil {
+			return err
+		}
+		if ov != nil {
+			if readable, err := overviewReadable(c, ov); err != nil {
+				return err
+			} else if !readable {
+				ov = nil
+			}
+		}
+		if ov == nil {
+			return c.WriteLine(ResponseText(ResponseArticleNotFound))
+		}
+		ov.Number = 0
+		overviews = append(overviews, *ov)
+	} else {
+		g := c.group
+		if g == nil {
+			return c.WriteLine(ResponseText(ResponseGroupNotSelected))
+		}
+
+		var low, high uint
+		if len(args) == 1 {
+			// Second form of OVER command
+			var ok bool
+			if low, high, ok = parseRange(args[0], *g); !ok {
+				return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+			}
+		} else {
+			// Third form of OVER command
+			if c.articleNumber == nil {
+				return c.WriteLine(ResponseText(ResponseArticleNotSelected))
+			}
+			low, high = *c.articleNumber, *c.articleNumber
+		}
+
+		var err error
+		if overviews, err = s.OverviewByGroup(c.Context(), *g, low, high); err != nil {
+			return err
+		}
+		if len(overviews) == 0 {
+			if len(args) == 0 {
+				return c.WriteLine(ResponseText(ResponseArticleNotSelected))
+			}
+			return c.WriteLine(ResponseText(ResponseArticleNotInGroup))
+		}
+	}
+
+	if err := c.WriteLine(ResponseText(ResponseOverviewFollows)); err != nil {
+		return err
+	}
+	format := s.OverviewFormat(c.Context())
+	for _, ov := range overviews {
+		if err := c.WriteLine(ov.Line(format)); err != nil {
+			return err
+		}
+	}
+	return c.WriteLine(".")
 }
 
 // Implements the POST command as described in section 6.3.1 of RFC3977
@@ -292,20 +989,31 @@ func PostHandler(c *Conn, args []string) error {
 		return nil
 	}
 
-	if c.AuthBackend().AnonymousPostingAllowed() {
+	if postingAllowed(c) {
 		if err := c.WriteLine(ResponseText(ResponsePostArticle)); err != nil {
 			return err
 		}
 		article, err := c.ReadArticle()
 		if err != nil {
-			c.WriteLine(ResponseText(ResponseArticleTransferFailed))
-			return err
+			if _, ok := err.(net.Error); ok {
+				return err
+			}
+			return c.WriteLine(ResponseText(ResponsePostingFailed))
 		}
 		if article != nil {
+			defer io.Copy(io.Discard, article.Body)
+			acl := c.Access()
+			for _, g := range articleGroups(article) {
+				if !acl.Allowed(g, PermissionSee|PermissionPost) {
+					return c.WriteLine(ResponseText(ResponsePostingFailed))
+				}
+			}
 			f := c.MessageFilter()
-			if !f(*article) {
+			if f == nil || !f(*article) {
 				s := c.StorageBackend()
-				s.PostArticle(*article)
+				if err := s.PostArticle(c.Context(), *article); err != nil {
+					return c.WriteLine(ResponseText(ResponsePostingFailed))
+				}
 				return c.WriteLine(ResponseText(ResponseArticlePosted))
 			} else {
 				return c.WriteLine(ResponseText(ResponsePostingFailed))
@@ -330,19 +1038,51 @@ func QuitHandler(c *Conn, args []string) error {
 	if err := c.WriteLine(ResponseText(ResponseConnectionClosing)); err != nil {
 		return err
 	}
+	if err := c.bw.Flush(); err != nil {
+		return err
+	}
 	return c.Close()
 }
 
 // Implements the STARTTLS command as described in section 2.2 of RFC4642
 func StarttlsHandler(c *Conn, args []string) error {
-	if c.server.TLSConfig != nil {
-		tlsConn := tls.Server(c.Conn, c.server.TLSConfig)
-		c.br = bufio.NewReader(tlsConn)
-		c.bw = bufio.NewWriter(tlsConn)
-		c.Conn = net.Conn(tlsConn)
-		c.isTLS = true
-		return nil
-	} else {
+	if len(args) != 0 {
+		return c.WriteLine(ResponseText(ResponseCommandSyntaxError))
+	}
+	if c.server.TLSConfig == nil {
 		return c.WriteLine(ResponseText(ResponseCommandNotSupported))
 	}
+	if c.isTLS || c.identity != "" {
+		return c.WriteLine(ResponseText(ResponsePermissionDenied))
+	}
+
+	if err := c.WriteLine(ResponseText(ResponseContinueTLS)); err != nil {
+		return err
+	}
+	if err := c.bw.Flush(); err != nil {
+		return err
+	}
+
+	// The client's TLS negotiation may already have been read from if the connection was being watched
+	raw := c.Conn
+	if b, ok := c.reader.takeByte(); ok {
+		raw = prefixConn{raw, io.MultiReader(bytes.NewReader(b), raw)}
+	}
+	tlsConn := tls.Server(raw, c.server.TLSConfig)
+	if err := c.server.handshake(tlsConn); err != nil {
+		// The connection is left in an unknown state by a failed negotiation so it can't be used any further
+		c.Conn.Close()
+		return err
+	}
+
+	// Anything the client sent in plaintext after STARTTLS is discarded along with the old reader, so commands
+	// can't be injected into the secured session as described in section 2.2.2 of RFC4642
+	c.stateMu.Lock()
+	c.Conn = tlsConn
+	c.stateMu.Unlock()
+	c.br = bufio.NewReader(c.reader)
+	c.bw = bufio.NewWriter(connWriter{c})
+	c.isTLS = true
+	c.reset()
+	return nil
 }
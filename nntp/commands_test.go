@@ -0,0 +1,384 @@
+package nntp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDate(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Clock = func() time.Time {
+		return time.Date(2021, time.March, 4, 5, 6, 7, 0, time.FixedZone("EST", -5*60*60))
+	}
+	c := dialTest(t, &srv)
+
+	c.send("DATE\r\n")
+	if line := strings.TrimSpace(c.expect("111")); line != "111 20210304100607" {
+		t.Errorf("got response %q, want the date in UTC", line)
+	}
+}
+
+// help sends HELP and returns the names of the commands listed
+func (c *testClient) help() []string {
+	c.t.Helper()
+	c.send("HELP\r\n")
+	c.expect("100")
+	var names []string
+	for {
+		line := strings.TrimSpace(c.expect(""))
+		if line == "." {
+			return names
+		}
+		names = append(names, strings.Fields(line)[0])
+	}
+}
+
+func TestHelpFollowsMode(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ModeSwitching = true
+	c := dialTest(t, &srv)
+
+	names := c.help()
+	if !hasCap(names, "IHAVE") || hasCap(names, "ARTICLE") {
+		t.Errorf("HELP in transit mode listed %v", names)
+	}
+
+	c.send("MODE READER\r\n")
+	c.expect("20")
+	names = c.help()
+	if hasCap(names, "IHAVE") || !hasCap(names, "ARTICLE") {
+		t.Errorf("HELP in reader mode listed %v", names)
+	}
+}
+
+func TestHelpFollowsAuthentication(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}), WithAuth(testAuth{}), WithCommand(Command{
+		Name:    "XSECRET",
+		Handler: Reject(ResponseCommandNotSupported),
+		Auth:    true,
+		Help:    "XSECRET",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialTest(t, &srv)
+
+	if hasCap(c.help(), "XSECRET") {
+		t.Error("HELP listed a command requiring authentication before the client authenticated")
+	}
+	c.send("XSECRET\r\n")
+	c.expect("480")
+
+	c.send("AUTHINFO USER reader\r\n")
+	c.expect("381")
+	c.send("AUTHINFO PASS secret\r\n")
+	c.expect("281")
+	if !hasCap(c.help(), "XSECRET") {
+		t.Error("HELP didn't list a command requiring authentication after the client authenticated")
+	}
+}
+
+// feedAuth is an authentication backend that permits any client to transfer articles
+type feedAuth struct{ testAuth }
+
+func (feedAuth) FeedAllowed(net.Addr) bool { return true }
+
+func TestIhaveMalformedHeader(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}), WithAuth(feedAuth{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialTest(t, &srv)
+
+	// The rest of the article, including a line that looks like a command, is discarded before responding
+	c.send("IHAVE <malformed@example.com>\r\n")
+	c.expect("335")
+	c.send("Subject without a colon\r\n\r\nHELP\r\n.\r\n")
+	c.expect("437")
+
+	// An article ending before its header does is answered without waiting for more input
+	c.send("IHAVE <truncated@example.com>\r\n")
+	c.expect("335")
+	c.send("Subject without a colon\r\n.\r\n")
+	c.expect("437")
+
+	c.send("HELP\r\n")
+	c.expect("100")
+}
+
+func TestTakethisMalformedHeader(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}), WithAuth(feedAuth{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialTest(t, &srv)
+	c.send("MODE STREAM\r\n")
+	c.expect("203")
+
+	// The article is discarded up to its terminating line, so the pipelined CHECK is the next command read
+	c.send("TAKETHIS <malformed@example.com>\r\nSubject without a colon\r\n\r\nCHECK <body@example.com>\r\n.\r\n" +
+		"CHECK <next@example.com>\r\n")
+	c.expect("439 <malformed@example.com>")
+	c.expect("238 <next@example.com>")
+}
+
+func TestPostMalformedHeader(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}), WithAuth(testAuth{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialTest(t, &srv)
+	c.send("AUTHINFO USER reader\r\n")
+	c.expect("381")
+	c.send("AUTHINFO PASS secret\r\n")
+	c.expect("281")
+
+	c.send("POST\r\n")
+	c.expect("340")
+	c.send("Subject without a colon\r\n\r\nHELP\r\n.\r\n")
+	c.expect("441")
+	c.send("HELP\r\n")
+	c.expect("100")
+}
+
+// failingAuth is an authentication backend that is unable to check credentials
+type failingAuth struct{ testAuth }
+
+func (failingAuth) Authenticate(string, string, net.Addr) (bool, error) {
+	return false, errors.New("password database unavailable")
+}
+
+func TestAuthinfoBackendError(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}), WithAuth(failingAuth{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var logged bytes.Buffer
+	srv.Log = log.New(&logged, "", 0)
+	c := dialTest(t, &srv)
+
+	c.send("AUTHINFO USER reader\r\n")
+	c.expect("381")
+	c.send("AUTHINFO PASS secret\r\n")
+	c.expect("403")
+	c.send("AUTHINFO SASL PLAIN AHJlYWRlcgBzZWNyZXQ=\r\n")
+	c.expect("403")
+	if !strings.Contains(logged.String(), "password database unavailable") {
+		t.Errorf("backend error not logged, got %q", logged.String())
+	}
+}
+
+// failingStorage is a storage backend whose first article can't be read
+type failingStorage struct{ testStorage }
+
+func (failingStorage) ArticleByGroup(g Group, number uint) (*Article, error) {
+	if number == 1 {
+		return nil, errors.New("spool unavailable")
+	}
+	return nil, nil
+}
+
+func TestRetrievalErrors(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(failingStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialTest(t, &srv)
+	c.send("GROUP test.group\r\n")
+	c.expect("211")
+
+	c.send("ARTICLE first\r\n")
+	c.expect("501")
+	c.send("ARTICLE 1\r\n")
+	c.expect("403")
+	c.send("ARTICLE 2\r\n")
+	c.expect("423")
+	c.send("HELP\r\n")
+	c.expect("100")
+}
+
+// articleStorage is a storage backend holding a single article that can be retrieved by message-id
+type articleStorage struct{ testStorage }
+
+func (articleStorage) ArticleByID(id string) (*Article, error) {
+	if id != "<one@example.com>" {
+		return nil, nil
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Message-ID", id)
+	header.Set("Newsgroups", "test.group")
+	header.Set("Subject", "Hello")
+	return &Article{header, strings.NewReader("Body\r\n")}, nil
+}
+
+func TestHdrAndXhdrByMessageID(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(articleStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialTest(t, &srv)
+
+	c.send("HDR Subject <one@example.com>\r\n")
+	c.expect("225")
+	if line := strings.TrimSpace(c.expect("")); line != "0 Hello" {
+		t.Errorf("HDR returned %q, want the article identified by 0", line)
+	}
+	c.expect(".")
+
+	c.send("XHDR Subject <one@example.com>\r\n")
+	c.expect("221")
+	if line := strings.TrimSpace(c.expect("")); line != "<one@example.com> Hello" {
+		t.Errorf("XHDR returned %q, want the article identified by its message-id", line)
+	}
+	c.expect(".")
+
+	c.send("XHDR Subject <missing@example.com>\r\n")
+	c.expect("430")
+}
+
+// slowOverviewStorage is a storage backend whose overview lookups wait until the request is cancelled
+type slowOverviewStorage struct {
+	testStorage
+	started   chan struct{}
+	cancelled chan error
+}
+
+func (slowOverviewStorage) OverviewFormat(context.Context) []string { return nil }
+
+func (s slowOverviewStorage) OverviewByGroup(ctx context.Context, g Group, low uint, high uint) ([]Overview, error) {
+	return nil, s.wait(ctx)
+}
+
+func (s slowOverviewStorage) OverviewByID(ctx context.Context, id string) (*Overview, error) {
+	return nil, s.wait(ctx)
+}
+
+func (s slowOverviewStorage) wait(ctx context.Context) error {
+	close(s.started)
+	<-ctx.Done()
+	s.cancelled <- ctx.Err()
+	return ctx.Err()
+}
+
+func TestDisconnectCancelsContext(t *testing.T) {
+	s := slowOverviewStorage{started: make(chan struct{}), cancelled: make(chan error, 1)}
+	srv, err := NewServer("", nil, WithStorage(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialTest(t, &srv)
+
+	c.send("OVER <one@example.com>\r\n")
+	select {
+	case <-s.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("OVER didn't reach the backend")
+	}
+	c.Close()
+
+	select {
+	case err := <-s.cancelled:
+		if err != context.Canceled {
+			t.Errorf("backend saw %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("context not cancelled after the client disconnected")
+	}
+}
+
+// timesStorage is a storage backend that records when its groups were created
+type timesStorage struct{ testStorage }
+
+func (timesStorage) CreationTimes() bool { return true }
+
+// listsKeyword reports whether the LIST capability advertises a keyword
+func listsKeyword(caps []string, keyword string) bool {
+	for _, v := range caps {
+		fields := strings.Fields(v)
+		if len(fields) == 0 || fields[0] != "LIST" {
+			continue
+		}
+		for _, f := range fields[1:] {
+			if f == keyword {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestListActiveTimesSupport(t *testing.T) {
+	tests := []struct {
+		name    string
+		storage Storage
+		want    bool
+		code    string
+	}{
+		{"without creation times", testStorage{}, false, "503"},
+		{"with creation times", timesStorage{}, true, "215"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			srv, err := NewServer("", nil, WithStorage(test.storage))
+			if err != nil {
+				t.Fatal(err)
+			}
+			c := dialTest(t, &srv)
+			if got := listsKeyword(c.capabilities(), "ACTIVE.TIMES"); got != test.want {
+				t.Errorf("ACTIVE.TIMES advertised = %v, want %v", got, test.want)
+			}
+			c.send("LIST ACTIVE.TIMES\r\n")
+			c.expect(test.code)
+		})
+	}
+}
+
+// zeroStorage is a storage backend whose group has a low water mark of zero, holding articles 1 and 2
+type zeroStorage struct{ testStorage }
+
+func (zeroStorage) Group(name string) *Group {
+	if name != "test.group" {
+		return nil
+	}
+	return &Group{Name: name, Count: 2, Min: 0, Max: 2, Flag: "y"}
+}
+
+func (zeroStorage) ArticleByGroup(g Group, number uint) (*Article, error) {
+	if number < 1 || number > 2 {
+		return nil, nil
+	}
+	header := textproto.MIMEHeader{}
+	header.Set("Message-ID", fmt.Sprintf("<%d@example.com>", number))
+	return &Article{header, strings.NewReader("")}, nil
+}
+
+func TestLastWithZeroLowWaterMark(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(zeroStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialTest(t, &srv)
+	c.send("GROUP test.group\r\n")
+	c.expect("211")
+	c.send("STAT 2\r\n")
+	c.expect("223")
+
+	c.send("LAST\r\n")
+	c.expect("223 1 <1@example.com>")
+	c.send("LAST\r\n")
+	c.expect("422")
+}
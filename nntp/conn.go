@@ -2,53 +2,76 @@ package nntp
 
 import (
 	"bufio"
+	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	"net/textproto"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Conn is a stateful connection that that allows for buffered IO
 type Conn struct {
 	net.Conn
-	br *bufio.Reader
-	bw *bufio.Writer
+	reader *connReader
+	br     *bufio.Reader
+	bw     *bufio.Writer
+
+	// readDeadline is the deadline last set for reading from the client
+	readDeadline time.Time
+	// running is set while a command handler is running, when the client is watched for disconnecting
+	running bool
 
 	isTLS bool
 
 	server        *Server
+	ctx           context.Context
+	cancel        context.CancelFunc
+	mode          Mode
 	articleNumber *uint
 	group         *Group
+
+	// identity is the name the client authenticated as with AUTHINFO, and pendingUser
+	// holds the name given by AUTHINFO USER until it is followed by AUTHINFO PASS
+	identity    string
+	pendingUser string
+
+	// acl caches the access control list of the client until its identity changes
+	acl       ACL
+	aclLoaded bool
+
+	// protocolErrors counts the malformed and unrecognized commands the client has sent
+	protocolErrors int
+
+	// timedOut is set when the client took too long to send an article read by the storage backend
+	timedOut bool
+
+	// stateMu guards idle and closing, which let the server close the connection from
+	// another goroutine while the client is between commands when it shuts down
+	stateMu sync.Mutex
+	idle    bool
+	closing bool
 }
 
-var commandMap = map[string]func(*Conn, []string) error{
-	"ARTICLE":      ArticleHander,
-	"BODY":         BodyHandler,
-	"CAPABILITIES": CapabilitiesHandler,
-	"DATE":         DateHandler,
-	"GROUP":        GroupHandler,
-	"HDR":          HdrHandler,
-	"HEAD":         HeadHandler,
-	"HELP":         HelpHandler,
-	"IHAVE":        IhaveHandler,
-	"LAST":         LastHandler,
-	"LIST":         ListHandler,
-	"LISTGROUP":    ListgroupHandler,
-	"MODE":         ModeHandler,
-	"NEWGROUPS":    NewgroupsHandler,
-	"NEWNEWS":      NewnewsHandler,
-	"NEXT":         NextHandler,
-	"OVER":         OverHandler,
-	"POST":         PostHandler,
-	"STAT":         StatHandler,
-	"QUIT":         QuitHandler,
-	"STARTTLS":     StarttlsHandler,
+// reset discards the session state built up by the client, such as its mode, selected group and identity,
+// as is required after the connection is secured with STARTTLS
+func (c *Conn) reset() {
+	c.mode = c.server.initialMode()
+	c.group = nil
+	c.articleNumber = nil
+	c.identity = ""
+	c.pendingUser = ""
+	c.acl = nil
+	c.aclLoaded = false
 }
 
 // StorageBackend is an alias for retrieving the storage interface associated
 // with the server that accepted this connection
-func (c *Conn) StorageBackend() Storage {
+func (c *Conn) StorageBackend() ContextStorage {
 	return c.server.storage
 }
 
@@ -60,16 +83,42 @@ func (c *Conn) MessageFilter() FilterFunc {
 
 // AuthBackend is an alias for retrieving the authentication interface associated
 // with the server that accepted this connection
-func (c *Conn) AuthBackend() Auth {
+func (c *Conn) AuthBackend() ContextAuth {
 	return c.server.auth
 }
 
+// Context returns the connection's context, which is cancelled when the client disconnects or the server is shut down.
+// Handlers pass it to the storage and authentication backends so they can abandon work for clients that have gone away,
+// from when a handler first asks for the context until it finishes the connection is watched for the client leaving
+func (c *Conn) Context() context.Context {
+	if c.running {
+		c.reader.startWatching()
+	}
+	return c.ctx
+}
+
+// Identity returns the name the client has authenticated as, or an empty string if it has not authenticated
+func (c *Conn) Identity() string {
+	return c.identity
+}
+
+// Access returns the access control list applying to the client based on its identity and address
+func (c *Conn) Access() ACL {
+	if !c.aclLoaded {
+		if auth := c.AuthBackend(); auth != nil {
+			c.acl = auth.Access(c.Context(), c.identity, c.RemoteAddr())
+		}
+		c.aclLoaded = true
+	}
+	return c.acl
+}
+
 // CurrentArticle retrieves the article pointed to by the connections current group
 // and article number, returning nil if there is no such existing article
 func (c *Conn) CurrentArticle() *Article {
 	if c.articleNumber != nil {
 		s := c.StorageBackend()
-		a, err := s.ArticleByGroup(*c.group, *c.articleNumber)
+		a, err := s.ArticleByGroup(c.Context(), *c.group, *c.articleNumber)
 		if err != nil {
 			return nil
 		} else {
@@ -80,24 +129,76 @@ func (c *Conn) CurrentArticle() *Article {
 	}
 }
 
-// ReadLine reads a CR-LF delimited line from the socket
+// flush sends any buffered responses to the client if there is no pipelined input waiting to be read,
+// this must be done before blocking on a read so the client isn't left waiting on a response it hasn't received
+func (c *Conn) flush() error {
+	if c.br.Buffered() == 0 {
+		return c.bw.Flush()
+	}
+	return nil
+}
+
+// ReadLine reads a CR-LF delimited line from the socket, failing if it isn't received within the server's read timeout
 func (c *Conn) ReadLine() (string, error) {
+	if err := c.flush(); err != nil {
+		return "", err
+	}
+	if err := c.SetReadDeadline(time.Now().Add(timeout(c.server.ReadTimeout, DefaultReadTimeout))); err != nil {
+		return "", err
+	}
 	reader := textproto.NewReader(c.br)
 	return reader.ReadLine()
 }
 
-// ReadArticle reads a dot-encoded, CR-LF delimited MIME message from the socket
+// ReadArticle reads a dot-encoded, CR-LF delimited MIME message from the socket. The whole article, including
+// the body read later by the caller, must be received within the server's article timeout
 func (c *Conn) ReadArticle() (*Article, error) {
+	if err := c.flush(); err != nil {
+		return nil, err
+	}
+	if err := c.SetReadDeadline(time.Now().Add(timeout(c.server.ArticleTimeout, DefaultArticleTimeout))); err != nil {
+		return nil, err
+	}
 	reader := textproto.NewReader(c.br)
-	header, err := reader.ReadMIMEHeader()
+
+	// The header is read up to the blank line ending it before being parsed, so that if it is malformed the rest
+	// of the article can be discarded without mistaking a terminating "." among the header lines for the body
+	var head bytes.Buffer
+	for {
+		line, err := reader.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		if line == "." {
+			// The article has no body, its header is parsed without one
+			head.WriteString("\r\n")
+			header, err := parseHeader(&head)
+			if err != nil {
+				return nil, err
+			}
+			return &Article{header, strings.NewReader("")}, nil
+		}
+		head.WriteString(strings.TrimPrefix(line, "."))
+		head.WriteString("\r\n")
+		if line == "" {
+			break
+		}
+	}
+
+	body := timeoutReader{reader.DotReader(), c}
+	header, err := parseHeader(&head)
 	if err != nil {
+		if _, derr := io.Copy(io.Discard, body); derr != nil {
+			return nil, derr
+		}
 		return nil, err
 	}
-	return &Article{
-		header,
-		reader.DotReader(),
-	}, nil
+	return &Article{header, body}, nil
+}
 
+// parseHeader parses the header of an article read by ReadArticle
+func parseHeader(head *bytes.Buffer) (textproto.MIMEHeader, error) {
+	return textproto.NewReader(bufio.NewReader(head)).ReadMIMEHeader()
 }
 
 // WriteLine formats a string and writes it to the socket with CR-LF line ending
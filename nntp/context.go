@@ -0,0 +1,192 @@
+package nntp
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// NewContextStorage adapts a Storage backend to the ContextStorage interface. The backend's methods can't be
+// interrupted, but methods able to return an error fail without calling the backend once the context is done
+func NewContextStorage(s Storage) ContextStorage {
+	if s == nil {
+		return nil
+	}
+	return storageAdapter{s}
+}
+
+// storageAdapter implements ContextStorage by calling a Storage backend
+type storageAdapter struct {
+	Storage Storage
+}
+
+func (a storageAdapter) HasArticle(_ context.Context, id string) bool {
+	return a.Storage.HasArticle(id)
+}
+
+func (a storageAdapter) Group(_ context.Context, name string) *Group {
+	return a.Storage.Group(name)
+}
+
+func (a storageAdapter) Groups(ctx context.Context) ([]Group, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.Storage.Groups()
+}
+
+func (a storageAdapter) PostArticle(ctx context.Context, article Article) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.Storage.PostArticle(article)
+}
+
+func (a storageAdapter) ArticleByID(ctx context.Context, id string) (*Article, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.Storage.ArticleByID(id)
+}
+
+func (a storageAdapter) ArticleByGroup(ctx context.Context, group Group, number uint) (*Article, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.Storage.ArticleByGroup(group, number)
+}
+
+func (a storageAdapter) ArticleNumbers(ctx context.Context, group Group, low uint, high uint) ([]uint, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.Storage.ArticleNumbers(group, low, high)
+}
+
+// NewContextAuth adapts an Auth backend to the ContextAuth interface. The backend's methods can't be interrupted,
+// but Authenticate fails without calling the backend once the context is done
+func NewContextAuth(a Auth) ContextAuth {
+	if a == nil {
+		return nil
+	}
+	return authAdapter{a}
+}
+
+// authAdapter implements ContextAuth by calling an Auth backend
+type authAdapter struct {
+	Auth Auth
+}
+
+func (a authAdapter) AnonymousPostingAllowed(context.Context) bool {
+	return a.Auth.AnonymousPostingAllowed()
+}
+
+func (a authAdapter) FeedAllowed(_ context.Context, addr net.Addr) bool {
+	return a.Auth.FeedAllowed(addr)
+}
+
+func (a authAdapter) Authenticate(ctx context.Context, username string, password string, addr net.Addr) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return a.Auth.Authenticate(username, password, addr)
+}
+
+func (a authAdapter) Access(_ context.Context, identity string, addr net.Addr) ACL {
+	return a.Auth.Access(identity, addr)
+}
+
+// distribPatsStorage returns the default Distribution header values of a storage backend implementing
+// either ContextDistribPatsStorage or DistribPatsStorage
+func distribPatsStorage(s ContextStorage) (ContextDistribPatsStorage, bool) {
+	switch b := backend(s).(type) {
+	case ContextDistribPatsStorage:
+		return b, true
+	case DistribPatsStorage:
+		return distribPatsAdapter{b}, true
+	}
+	return nil, false
+}
+
+// distribPatsAdapter implements ContextDistribPatsStorage by calling a DistribPatsStorage backend
+type distribPatsAdapter struct {
+	DistribPatsStorage
+}
+
+func (a distribPatsAdapter) DistribPats(ctx context.Context) ([]DistribPat, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.DistribPatsStorage.DistribPats()
+}
+
+// overviewStorage returns the overview database of a storage backend implementing
+// either ContextOverviewStorage or OverviewStorage
+func overviewStorage(s ContextStorage) (ContextOverviewStorage, bool) {
+	switch b := backend(s).(type) {
+	case ContextOverviewStorage:
+		return b, true
+	case OverviewStorage:
+		return overviewAdapter{b}, true
+	}
+	return nil, false
+}
+
+// overviewAdapter implements ContextOverviewStorage by calling an OverviewStorage backend
+type overviewAdapter struct {
+	OverviewStorage
+}
+
+func (a overviewAdapter) OverviewFormat(context.Context) []string {
+	return a.OverviewStorage.OverviewFormat()
+}
+
+func (a overviewAdapter) OverviewByGroup(ctx context.Context, group Group, low uint, high uint) ([]Overview, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.OverviewStorage.OverviewByGroup(group, low, high)
+}
+
+func (a overviewAdapter) OverviewByID(ctx context.Context, id string) (*Overview, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.OverviewStorage.OverviewByID(id)
+}
+
+// arrivalStorage returns the arrival index of a storage backend implementing
+// either ContextArrivalStorage or ArrivalStorage
+func arrivalStorage(s ContextStorage) (ContextArrivalStorage, bool) {
+	switch b := backend(s).(type) {
+	case ContextArrivalStorage:
+		return b, true
+	case ArrivalStorage:
+		return arrivalAdapter{b}, true
+	}
+	return nil, false
+}
+
+// arrivalAdapter implements ContextArrivalStorage by calling an ArrivalStorage backend
+type arrivalAdapter struct {
+	ArrivalStorage
+}
+
+func (a arrivalAdapter) ArticlesSince(ctx context.Context, since time.Time) ([]Arrival, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return a.ArrivalStorage.ArticlesSince(since)
+}
+
+// backend returns the storage or authentication backend given to the server, unwrapping the adapters
+// so that optional interfaces such as OverviewStorage and HostAuth can be asserted against it
+func backend(v interface{}) interface{} {
+	switch a := v.(type) {
+	case storageAdapter:
+		return a.Storage
+	case authAdapter:
+		return a.Auth
+	}
+	return v
+}
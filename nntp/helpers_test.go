@@ -0,0 +1,169 @@
+package nntp
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStorage is a storage backend holding a single empty group
+type testStorage struct{}
+
+func (testStorage) HasArticle(string) bool { return false }
+
+func (testStorage) Group(name string) *Group {
+	if name != "test.group" {
+		return nil
+	}
+	return &Group{Name: name, Min: 1, Flag: "y"}
+}
+
+func (s testStorage) Groups() ([]Group, error) {
+	return []Group{*s.Group("test.group")}, nil
+}
+
+func (testStorage) PostArticle(Article) error                    { return nil }
+func (testStorage) ArticleByID(string) (*Article, error)         { return nil, nil }
+func (testStorage) ArticleByGroup(Group, uint) (*Article, error) { return nil, nil }
+func (testStorage) ArticleNumbers(Group, uint, uint) ([]uint, error) {
+	return nil, nil
+}
+
+// testAuth accepts the password "secret" for any user
+type testAuth struct{}
+
+func (testAuth) AnonymousPostingAllowed() bool { return false }
+func (testAuth) FeedAllowed(net.Addr) bool     { return false }
+func (testAuth) Access(string, net.Addr) ACL   { return nil }
+func (testAuth) Authenticate(username string, password string, addr net.Addr) (bool, error) {
+	return password == "secret", nil
+}
+
+// testTLSConfig creates a server configuration with a self-signed certificate
+func testTLSConfig(t *testing.T) *tls.Config {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	}
+}
+
+// testClient is the client end of a connection to a server under test
+type testClient struct {
+	t *testing.T
+	net.Conn
+	r *bufio.Reader
+}
+
+// dialTest starts serving one end of a pipe and returns a client for the other once the greeting has been read
+func dialTest(t *testing.T, srv *Server) *testClient {
+	t.Helper()
+	client, server := net.Pipe()
+	go srv.serve(srv.NewConn(server))
+	t.Cleanup(func() { client.Close() })
+
+	c := &testClient{t: t, Conn: client, r: bufio.NewReader(client)}
+	c.expect("20")
+	return c
+}
+
+// send writes raw protocol text to the server
+func (c *testClient) send(text string) {
+	c.t.Helper()
+	c.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.Write([]byte(text)); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// expect reads a response line and fails the test unless it begins with prefix
+func (c *testClient) expect(prefix string) string {
+	c.t.Helper()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("reading response: %v", err)
+	}
+	if !strings.HasPrefix(line, prefix) {
+		c.t.Fatalf("got response %q, want %q", strings.TrimSpace(line), prefix)
+	}
+	return line
+}
+
+// expectClosed fails the test unless the server has closed the connection
+func (c *testClient) expectClosed() {
+	c.t.Helper()
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if line, err := c.r.ReadString('\n'); err != io.EOF {
+		c.t.Errorf("read %q, %v after the response, want the connection closed", line, err)
+	}
+}
+
+// capabilities sends CAPABILITIES and returns the advertised capabilities
+func (c *testClient) capabilities() []string {
+	c.t.Helper()
+	c.send("CAPABILITIES\r\n")
+	c.expect("101")
+	var caps []string
+	for {
+		line := strings.TrimSpace(c.expect(""))
+		if line == "." {
+			return caps
+		}
+		caps = append(caps, line)
+	}
+}
+
+// starttls negotiates TLS after the server has accepted STARTTLS
+func (c *testClient) starttls() {
+	c.t.Helper()
+	conn := tls.Client(c.Conn, &tls.Config{InsecureSkipVerify: true})
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if err := conn.Handshake(); err != nil {
+		c.t.Fatal(err)
+	}
+	conn.SetDeadline(time.Time{})
+	c.Conn = conn
+	c.r = bufio.NewReader(conn)
+}
+
+func newTLSTestServer(t *testing.T) *Server {
+	srv, err := NewServer("", testTLSConfig(t), WithStorage(testStorage{}), WithAuth(testAuth{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &srv
+}
+
+func hasCap(caps []string, capability string) bool {
+	for _, v := range caps {
+		if strings.HasPrefix(v, capability) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package nntp
+
+import (
+	"fmt"
+	"strings"
+)
+
+// listKeyword describes one of the keywords accepted by the LIST command
+type listKeyword struct {
+	// wildmat is true if the keyword accepts an optional wildmat argument
+	wildmat bool
+	// supported reports whether the storage backend is able to provide the information
+	supported func(ContextStorage) bool
+	// lines produces the body of the response, arg is empty if no argument was given
+	lines func(c *Conn, arg string) ([]string, error)
+}
+
+// listKeywordOrder is the order keywords are advertised in the LIST capability
+var listKeywordOrder = []string{
+	"ACTIVE",
+	"ACTIVE.TIMES",
+	"DISTRIB.PATS",
+	"HEADERS",
+	"NEWSGROUPS",
+	"OVERVIEW.FMT",
+}
+
+var listKeywords = map[string]listKeyword{
+	"ACTIVE": {
+		wildmat:   true,
+		supported: func(ContextStorage) bool { return true },
+		lines:     listActive,
+	},
+	"ACTIVE.TIMES": {
+		wildmat: true,
+		supported: func(s ContextStorage) bool {
+			t, ok := backend(s).(GroupTimesStorage)
+			return ok && t.CreationTimes()
+		},
+		lines: listActiveTimes,
+	},
+	"DISTRIB.PATS": {
+		supported: func(s ContextStorage) bool {
+			_, ok := distribPatsStorage(s)
+			return ok
+		},
+		lines: listDistribPats,
+	},
+	"HEADERS": {
+		supported: func(ContextStorage) bool { return true },
+		lines:     listHeaders,
+	},
+	"NEWSGROUPS": {
+		wildmat:   true,
+		supported: func(ContextStorage) bool { return true },
+		lines:     listNewsgroups,
+	},
+	"OVERVIEW.FMT": {
+		supported: func(s ContextStorage) bool {
+			_, ok := overviewStorage(s)
+			return ok
+		},
+		lines: listOverviewFmt,
+	},
+}
+
+// listCapability returns the LIST capability line advertising the keywords supported by the storage backend
+func listCapability(s ContextStorage) string {
+	caps := []string{"LIST"}
+	for _, keyword := range listKeywordOrder {
+		if listKeywords[keyword].supported(s) {
+			caps = append(caps, keyword)
+		}
+	}
+	return strings.Join(caps, " ")
+}
+
+// matchingGroups returns the groups known to the storage backend that are visible to the client and whose names
+// match the wildmat, an empty wildmat matches every group
+func matchingGroups(c *Conn, wildmat string) ([]Group, error) {
+	groups, err := c.StorageBackend().Groups(c.Context())
+	if err != nil {
+		return nil, err
+	}
+	acl := c.Access()
+	matched := make([]Group, 0, len(groups))
+	for _, g := range groups {
+		if !acl.Allowed(g.Name, PermissionSee) {
+			continue
+		}
+		if wildmat == "" || MatchWildmat(wildmat, g.Name) {
+			matched = append(matched, g)
+		}
+	}
+	return matched, nil
+}
+
+// Implements LIST ACTIVE as described in section 7.6.3 of RFC3977
+func listActive(c *Conn, wildmat string) ([]string, error) {
+	groups, err := matchingGroups(c, wildmat)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, len(groups))
+	for _, g := range groups {
+		lines = append(lines, activeLine(g))
+	}
+	return lines, nil
+}
+
+// activeLine formats a group in the format used by LIST ACTIVE and NEWGROUPS
+func activeLine(g Group) string {
+	return fmt.Sprintf("%s %d %d %s", g.Name, g.Max, g.Min, g.Flag)
+}
+
+// Implements LIST ACTIVE.TIMES as described in section 7.6.4 of RFC3977,
+// groups without a recorded creation time are omitted
+func listActiveTimes(c *Conn, wildmat string) ([]string, error) {
+	groups, err := matchingGroups(c, wildmat)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, len(groups))
+	for _, g := range groups {
+		if g.Created.IsZero() {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %d %s", g.Name, g.Created.Unix(), g.Creator))
+	}
+	return lines, nil
+}
+
+// Implements LIST DISTRIB.PATS as described in section 7.6.5 of RFC3977
+func listDistribPats(c *Conn, _ string) ([]string, error) {
+	s, _ := distribPatsStorage(c.StorageBackend())
+	pats, err := s.DistribPats(c.Context())
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, len(pats))
+	for _, p := range pats {
+		lines = append(lines, fmt.Sprintf("%d:%s:%s", p.Weight, p.Wildmat, p.Value))
+	}
+	return lines, nil
+}
+
+// Implements LIST HEADERS as described in section 8.6 of RFC3977.
+// Any header can be retrieved by HDR since fields missing from the overview database are read from the article itself
+func listHeaders(c *Conn, _ string) ([]string, error) {
+	return []string{":", ":bytes", ":lines"}, nil
+}
+
+// Implements LIST NEWSGROUPS as described in section 7.6.6 of RFC3977
+func listNewsgroups(c *Conn, wildmat string) ([]string, error) {
+	groups, err := matchingGroups(c, wildmat)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, 0, len(groups))
+	for _, g := range groups {
+		lines = append(lines, fmt.Sprintf("%s\t%s", g.Name, g.Description))
+	}
+	return lines, nil
+}
+
+// Implements LIST OVERVIEW.FMT as described in section 8.4 of RFC3977
+func listOverviewFmt(c *Conn, _ string) ([]string, error) {
+	s, _ := overviewStorage(c.StorageBackend())
+	return s.OverviewFormat(c.Context()), nil
+}
@@ -0,0 +1,103 @@
+package nntp_test
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Chemiseblanc/gonews/nntp"
+	"github.com/Chemiseblanc/gonews/nntp/storage"
+)
+
+// postingAuth lets every client post and read every group
+type postingAuth struct{}
+
+func (postingAuth) AnonymousPostingAllowed() bool                       { return true }
+func (postingAuth) FeedAllowed(net.Addr) bool                           { return false }
+func (postingAuth) Access(string, net.Addr) nntp.ACL                    { return nil }
+func (postingAuth) Authenticate(string, string, net.Addr) (bool, error) { return false, nil }
+
+// serveMemory serves a Memory backend holding the given groups on a local port and returns a client connected to it
+func serveMemory(t *testing.T, groups ...string) *textproto.Conn {
+	t.Helper()
+	m := storage.NewMemory()
+	m.Hostname = "news.example.com"
+	for _, name := range groups {
+		m.AddGroup(nntp.Group{Name: name})
+	}
+	srv, err := nntp.NewServer("", nil, nntp.WithStorage(m), nntp.WithAuth(postingAuth{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	c := textproto.NewConn(conn)
+	t.Cleanup(func() { c.Close() })
+	if _, _, err := c.ReadCodeLine(200); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// cmd sends a command and fails the test unless it is answered with the expected code
+func cmd(t *testing.T, c *textproto.Conn, code int, format string, args ...interface{}) string {
+	t.Helper()
+	if _, err := c.Cmd(format, args...); err != nil {
+		t.Fatal(err)
+	}
+	_, msg, err := c.ReadCodeLine(code)
+	if err != nil {
+		t.Fatalf("%s: %v", format, err)
+	}
+	return msg
+}
+
+func TestMemoryPostAndRead(t *testing.T) {
+	c := serveMemory(t, "misc.test")
+
+	cmd(t, c, 340, "POST")
+	w := c.DotWriter()
+	w.Write([]byte("Message-ID: <one@example.com>\r\nNewsgroups: misc.test\r\nSubject: Hello\r\nFrom: tester@example.com\r\n\r\nHello world\r\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.ReadCodeLine(240); err != nil {
+		t.Fatal(err)
+	}
+
+	if msg := cmd(t, c, 211, "GROUP misc.test"); !strings.HasPrefix(msg, "1 1 1 misc.test") {
+		t.Errorf("GROUP = %q, want a single article", msg)
+	}
+
+	cmd(t, c, 220, "ARTICLE 1")
+	lines, err := c.ReadDotLines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := strings.Join(lines, "\n"); !strings.Contains(text, "Subject: Hello") || !strings.HasSuffix(text, "\nHello world") {
+		t.Errorf("ARTICLE returned %q", text)
+	}
+
+	cmd(t, c, 224, "OVER 1-")
+	lines, err = c.ReadDotLines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "1\tHello\ttester@example.com\t") {
+		t.Errorf("OVER returned %q", lines)
+	}
+
+	cmd(t, c, 430, "ARTICLE <missing@example.com>")
+}
@@ -0,0 +1,50 @@
+package nntp
+
+// Middleware wraps the handler of a command to add behaviour shared by every command, such as auditing, rate
+// limiting or timing. It is given the command being run and the next handler in the chain, the returned handler
+// may inspect the arguments, short-circuit the command by writing its own response instead of calling next,
+// or observe the error returned by next
+type Middleware func(cmd Command, next HandlerFunc) HandlerFunc
+
+// Use adds middleware around every command handled by the server, the first middleware added is the outermost.
+// It must not be called once the server has started accepting connections
+func (srv *Server) Use(middleware ...Middleware) {
+	srv.middleware = append(srv.middleware, middleware...)
+}
+
+// WithMiddleware adds middleware around every command handled by the server
+func WithMiddleware(middleware ...Middleware) ServerOption {
+	return func(srv *Server) {
+		srv.Use(middleware...)
+	}
+}
+
+// Reject is a handler that answers a command with the given response code, middleware can return it to
+// refuse a command without running it
+func Reject(code int, args ...interface{}) HandlerFunc {
+	return func(c *Conn, _ []string) error {
+		return c.WriteLine(ResponseText(code, args...))
+	}
+}
+
+// dispatch checks the client may use the command in its current mode and authentication state before running it
+func dispatch(cmd Command) HandlerFunc {
+	return func(c *Conn, args []string) error {
+		if cmd.Mode != 0 && cmd.Mode&c.mode == 0 {
+			return c.WriteLine(ResponseText(ResponsePermissionDenied))
+		}
+		if cmd.Auth && c.identity == "" {
+			return c.WriteLine(ResponseText(ResponseAuthRequired))
+		}
+		return cmd.Handler(c, args)
+	}
+}
+
+// chain wraps a command's handler in the server's middleware
+func (srv *Server) chain(cmd Command) HandlerFunc {
+	handler := dispatch(cmd)
+	for i := len(srv.middleware) - 1; i >= 0; i-- {
+		handler = srv.middleware[i](cmd, handler)
+	}
+	return handler
+}
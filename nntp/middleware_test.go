@@ -0,0 +1,128 @@
+package nntp
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// calls records the steps taken while handling the XTEST command
+type calls struct {
+	mu    sync.Mutex
+	steps []string
+	err   error
+}
+
+func (r *calls) add(step string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, step)
+}
+
+func (r *calls) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.steps, ", ")
+}
+
+// recordingServer creates a server with an XTEST command that records it ran and returns err
+func recordingServer(t *testing.T, r *calls, err error, middleware ...Middleware) *Server {
+	t.Helper()
+	srv, serr := NewServer("", nil, WithStorage(testStorage{}), WithCommand(Command{
+		Name: "XTEST",
+		Handler: func(c *Conn, args []string) error {
+			r.add("handler")
+			if err != nil {
+				return err
+			}
+			return c.WriteLine(ResponseText(ResponseHelpFollows))
+		},
+	}), WithMiddleware(middleware...))
+	if serr != nil {
+		t.Fatal(serr)
+	}
+	return &srv
+}
+
+// recordXtest returns middleware that records when it is entered and left while handling XTEST
+func recordXtest(name string, r *calls) Middleware {
+	return func(cmd Command, next HandlerFunc) HandlerFunc {
+		if cmd.Name != "XTEST" {
+			return next
+		}
+		return func(c *Conn, args []string) error {
+			r.add(name + " entered")
+			err := next(c, args)
+			r.add(name + " left")
+			return err
+		}
+	}
+}
+
+// sync waits for the server to finish the previous command by running another one
+func (c *testClient) sync() {
+	c.t.Helper()
+	c.send("DATE\r\n")
+	c.expect("111")
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	r := &calls{}
+	srv := recordingServer(t, r, nil, recordXtest("first", r))
+	srv.Use(recordXtest("second", r))
+	c := dialTest(t, srv)
+
+	c.send("XTEST\r\n")
+	c.expect("100")
+	c.sync()
+	want := "first entered, second entered, handler, second left, first left"
+	if got := r.String(); got != want {
+		t.Errorf("XTEST ran %q, want %q", got, want)
+	}
+}
+
+func TestMiddlewareReject(t *testing.T) {
+	r := &calls{}
+	srv := recordingServer(t, r, nil, func(cmd Command, next HandlerFunc) HandlerFunc {
+		if cmd.Name == "XTEST" {
+			return Reject(ResponsePermissionDenied)
+		}
+		return next
+	})
+	c := dialTest(t, srv)
+
+	c.send("XTEST\r\n")
+	c.expect("502")
+	c.sync()
+	if got := r.String(); got != "" {
+		t.Errorf("XTEST ran %q, want the handler skipped", got)
+	}
+}
+
+func TestMiddlewareSeesError(t *testing.T) {
+	r := &calls{}
+	failure := errors.New("backend unavailable")
+	srv := recordingServer(t, r, failure, func(cmd Command, next HandlerFunc) HandlerFunc {
+		if cmd.Name != "XTEST" {
+			return next
+		}
+		return func(c *Conn, args []string) error {
+			err := next(c, args)
+			r.mu.Lock()
+			r.err = err
+			r.mu.Unlock()
+			return err
+		}
+	})
+	c := dialTest(t, srv)
+
+	c.send("XTEST\r\n")
+	c.expect("403")
+	c.sync()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != failure {
+		t.Errorf("middleware saw %v, want the handler's error", r.err)
+	}
+}
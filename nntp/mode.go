@@ -0,0 +1,38 @@
+package nntp
+
+// Mode is a set of the kinds of command a connection is able to use, as described in section 3.4.2 of RFC3977
+type Mode int
+
+const (
+	// ModeTransit permits the commands used by peers to feed articles to the server
+	ModeTransit Mode = 1 << iota
+	// ModeReader permits the commands used by newsreaders to read and post articles
+	ModeReader
+
+	// ModeAny is the mode of connections to servers that aren't mode-switching,
+	// and the requirement of commands that can be used in either mode
+	ModeAny = ModeTransit | ModeReader
+)
+
+// initialMode returns the mode connections start in, mode-switching servers start connections in transit mode
+// and switch to reader mode on MODE READER while other servers permit every command at all times
+func (srv *Server) initialMode() Mode {
+	if srv.ModeSwitching {
+		return ModeTransit
+	}
+	return ModeAny
+}
+
+// Mode returns the kinds of command the connection is currently able to use
+func (c *Conn) Mode() Mode {
+	return c.mode
+}
+
+// postingAllowed reports whether the client may post articles to at least one group
+func postingAllowed(c *Conn) bool {
+	auth := c.AuthBackend()
+	if auth == nil || (c.identity == "" && !auth.AnonymousPostingAllowed(c.Context())) {
+		return false
+	}
+	return c.Access().Grants(PermissionSee | PermissionPost)
+}
@@ -1,8 +1,11 @@
 package nntp
 
 import (
+	"context"
 	"io"
+	"net"
 	"net/textproto"
+	"time"
 )
 
 // Group is a structure describing a newsgroup the server participates in
@@ -13,6 +16,17 @@ type Group struct {
 	Max         uint
 	Count       uint
 	Flag        string
+
+	// Created is when the group was created and Creator identifies who created it, as recorded in INN's active.times
+	Created time.Time
+	Creator string
+}
+
+// DistribPat is an entry of the distribution patterns list returned by LIST DISTRIB.PATS
+type DistribPat struct {
+	Weight  int
+	Wildmat string
+	Value   string
 }
 
 // Article is a structure describing a news article.
@@ -26,23 +40,118 @@ type Article struct {
 	Body io.Reader
 }
 
+// Arrival records when an article arrived at the server and the groups it was filed in
+type Arrival struct {
+	MessageID  string
+	Newsgroups []string
+	Time       time.Time
+}
+
 // MessageID is a convenience function for retrieving the contents of the MessageID header field
 func (a *Article) MessageID() string {
-	return a.Get("MessageID")
+	return a.Get("Message-ID")
 }
 
 // Storage is an interface for operations against the articles served by the newsserver
 type Storage interface {
 	HasArticle(string) bool
 	Group(string) *Group
+	Groups() ([]Group, error)
 	PostArticle(Article) error
 	ArticleByID(string) (*Article, error)
 	ArticleByGroup(Group, uint) (*Article, error)
+	// ArticleNumbers returns the numbers of the existing articles in a group between low and high inclusive in ascending order
+	ArticleNumbers(Group, uint, uint) ([]uint, error)
+}
+
+// ContextStorage is a variant of Storage whose methods are given the context of the connection making the request,
+// which is cancelled when the client disconnects or the server is shut down. Storage backends are adapted to it
+// by NewContextStorage
+type ContextStorage interface {
+	HasArticle(context.Context, string) bool
+	Group(context.Context, string) *Group
+	Groups(context.Context) ([]Group, error)
+	PostArticle(context.Context, Article) error
+	ArticleByID(context.Context, string) (*Article, error)
+	ArticleByGroup(context.Context, Group, uint) (*Article, error)
+	// ArticleNumbers returns the numbers of the existing articles in a group between low and high inclusive in ascending order
+	ArticleNumbers(context.Context, Group, uint, uint) ([]uint, error)
+}
+
+// DistribPatsStorage is an optional interface for storage backends that provide default Distribution header values
+type DistribPatsStorage interface {
+	DistribPats() ([]DistribPat, error)
+}
+
+// OverviewStorage is an optional interface for storage backends that maintain an overview database
+type OverviewStorage interface {
+	// OverviewFormat returns the fields held in the overview database in the format of LIST OVERVIEW.FMT
+	OverviewFormat() []string
+	// OverviewByGroup returns the overview entries of the existing articles numbered between low and high inclusive
+	OverviewByGroup(Group, uint, uint) ([]Overview, error)
+	// OverviewByID returns the overview entry of an article, or nil if there is no such article
+	OverviewByID(string) (*Overview, error)
+}
+
+// ArrivalStorage is an optional interface for storage backends that index articles by their time of arrival
+type ArrivalStorage interface {
+	// ArticlesSince returns the articles that arrived at or after the given time in order of arrival
+	ArticlesSince(time.Time) ([]Arrival, error)
+}
+
+// GroupTimesStorage is an optional interface for storage backends that record when each group was created
+type GroupTimesStorage interface {
+	// CreationTimes reports whether the Created and Creator fields of groups are filled in
+	CreationTimes() bool
+}
+
+// ContextDistribPatsStorage is a variant of DistribPatsStorage for backends that are able to cancel requests made
+// for clients that have gone away
+type ContextDistribPatsStorage interface {
+	DistribPats(context.Context) ([]DistribPat, error)
+}
+
+// ContextOverviewStorage is a variant of OverviewStorage for backends that are able to cancel requests made
+// for clients that have gone away
+type ContextOverviewStorage interface {
+	OverviewFormat(context.Context) []string
+	OverviewByGroup(context.Context, Group, uint, uint) ([]Overview, error)
+	OverviewByID(context.Context, string) (*Overview, error)
+}
+
+// ContextArrivalStorage is a variant of ArrivalStorage for backends that are able to cancel requests made
+// for clients that have gone away
+type ContextArrivalStorage interface {
+	ArticlesSince(context.Context, time.Time) ([]Arrival, error)
 }
 
 // Auth is an interface for validating whether or not to permit actions taken by an active connection
 type Auth interface {
 	AnonymousPostingAllowed() bool
+	// FeedAllowed reports whether the client at the given address is a peer permitted to transfer articles with IHAVE
+	FeedAllowed(net.Addr) bool
+	// Authenticate verifies the credentials given by AUTHINFO from the client at the given address,
+	// returning false if they were rejected
+	Authenticate(username string, password string, addr net.Addr) (bool, error)
+	// Access returns the access control list for a client given its authenticated identity, which is empty for
+	// anonymous clients, and its address. Returning nil places no restrictions on the client
+	Access(identity string, addr net.Addr) ACL
+}
+
+// ContextAuth is a variant of Auth whose methods are given the context of the connection making the request,
+// which is cancelled when the client disconnects or the server is shut down. Auth backends are adapted to it
+// by NewContextAuth
+type ContextAuth interface {
+	AnonymousPostingAllowed(context.Context) bool
+	FeedAllowed(context.Context, net.Addr) bool
+	Authenticate(ctx context.Context, username string, password string, addr net.Addr) (bool, error)
+	Access(ctx context.Context, identity string, addr net.Addr) ACL
+}
+
+// HostAuth is an optional interface for authentication backends that refuse service to some clients entirely,
+// clients at addresses that aren't allowed are greeted with 502 and disconnected
+type HostAuth interface {
+	HostAllowed(net.Addr) bool
 }
 
 // FilterFunc is a type of function for determining if the newsserver should accept a posted or transferred article
@@ -0,0 +1,119 @@
+package nntp
+
+import (
+	"bytes"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+)
+
+// DefaultOverviewFormat is the list of fields recorded in the overview database as returned by LIST OVERVIEW.FMT.
+// The first seven fields are mandated by section 8.4 of RFC3977 and must appear in this order
+var DefaultOverviewFormat = []string{
+	"Subject:",
+	"From:",
+	"Date:",
+	"Message-ID:",
+	"References:",
+	":bytes",
+	":lines",
+	"Xref:full",
+}
+
+// Overview is an entry of the overview database describing a single article
+type Overview struct {
+	// Number is the article number within the group it was retrieved from, or 0 if it was retrieved by message-id
+	Number uint
+
+	Subject    string
+	From       string
+	Date       string
+	MessageID  string
+	References string
+	Xref       string
+
+	// Bytes is the size of the article in octets and Lines is the number of lines in the body
+	Bytes uint
+	Lines uint
+}
+
+// NewOverview builds the overview entry of an article from its headers and body text
+func NewOverview(number uint, header textproto.MIMEHeader, body []byte) Overview {
+	var h bytes.Buffer
+	http.Header(header).Write(&h)
+
+	return Overview{
+		Number:     number,
+		Subject:    header.Get("Subject"),
+		From:       header.Get("From"),
+		Date:       header.Get("Date"),
+		MessageID:  header.Get("Message-ID"),
+		References: header.Get("References"),
+		Xref:       header.Get("Xref"),
+		Bytes:      uint(h.Len() + len("\r\n") + len(body)),
+		Lines:      uint(bytes.Count(body, []byte("\n"))),
+	}
+}
+
+// Field returns the value of a header or metadata item held in the overview entry,
+// the second return value is false if the field is not recorded in the overview database
+func (o Overview) Field(name string) (string, bool) {
+	switch strings.ToLower(name) {
+	case "subject":
+		return o.Subject, true
+	case "from":
+		return o.From, true
+	case "date":
+		return o.Date, true
+	case "message-id":
+		return o.MessageID, true
+	case "references":
+		return o.References, true
+	case "xref":
+		return o.Xref, true
+	case ":bytes":
+		return strconv.FormatUint(uint64(o.Bytes), 10), true
+	case ":lines":
+		return strconv.FormatUint(uint64(o.Lines), 10), true
+	default:
+		return "", false
+	}
+}
+
+// overviewHasField reports whether a header or metadata item is recorded in the overview database
+func overviewHasField(format []string, name string) bool {
+	for _, f := range format {
+		f = strings.TrimSuffix(f, ":full")
+		if !strings.HasPrefix(f, ":") {
+			f = strings.TrimSuffix(f, ":")
+		}
+		if strings.EqualFold(f, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Line formats the overview entry as a single line of an OVER response using the given overview format
+func (o Overview) Line(format []string) string {
+	fields := make([]string, 0, len(format)+1)
+	fields = append(fields, strconv.FormatUint(uint64(o.Number), 10))
+	for _, f := range format {
+		full := strings.HasSuffix(f, ":full")
+		name := strings.TrimSuffix(strings.TrimSuffix(f, ":full"), ":")
+		if strings.HasPrefix(f, ":") {
+			name = f
+		}
+		value, _ := o.Field(name)
+		value = overviewEscaper.Replace(value)
+		if full && value != "" {
+			value = name + ": " + value
+		}
+		fields = append(fields, value)
+	}
+	return strings.Join(fields, "\t")
+}
+
+// overviewEscaper replaces the characters that are not permitted in overview fields as required by section 8.3.2 of RFC3977
+var overviewEscaper = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ", "\x00", " ")
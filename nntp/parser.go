@@ -0,0 +1,120 @@
+package nntp
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxLineLength is the maximum length of a command line including the terminating CR-LF,
+// as described in section 3.1 of RFC3977
+const MaxLineLength = 512
+
+// DefaultMaxProtocolErrors is the number of malformed or unrecognized commands a client may send before it is
+// disconnected if the server doesn't set MaxProtocolErrors
+const DefaultMaxProtocolErrors = 10
+
+// errLineTooLong is returned by readCommand when a command line exceeds MaxLineLength
+var errLineTooLong = errors.New("nntp: command line too long")
+
+// readCommand reads a command line from the client within the server's read timeout. A line longer than
+// MaxLineLength is discarded up to its line ending and errLineTooLong is returned so the next command can be read
+func (c *Conn) readCommand() (string, error) {
+	if err := c.flush(); err != nil {
+		return "", err
+	}
+	if err := c.SetReadDeadline(time.Now().Add(timeout(c.server.ReadTimeout, DefaultReadTimeout))); err != nil {
+		return "", err
+	}
+
+	var line []byte
+	tooLong := false
+	for {
+		frag, err := c.br.ReadSlice('\n')
+		if !tooLong {
+			line = append(line, frag...)
+			if len(line) > MaxLineLength {
+				tooLong = true
+				line = nil
+			}
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		break
+	}
+	if tooLong {
+		return "", errLineTooLong
+	}
+
+	line = line[:len(line)-1]
+	if len(line) > 0 && line[len(line)-1] == '\r' {
+		line = line[:len(line)-1]
+	}
+	return string(line), nil
+}
+
+// parseCommand splits a command line into its keyword, in upper-case, and arguments, which are separated by spaces
+// or tabs as described in section 3.1 of RFC3977. If the line is malformed the response code to send is returned,
+// 500 if there is no recognizable keyword and 501 if the arguments are not valid UTF-8 or contain NUL
+func parseCommand(line string) (string, []string, int) {
+	words := strings.FieldsFunc(line, func(r rune) bool {
+		return r == ' ' || r == '\t'
+	})
+	if len(words) == 0 {
+		return "", nil, ResponseCommandNotRecognized
+	}
+
+	keyword := words[0]
+	for i := 0; i < len(keyword); i++ {
+		if keyword[i] <= ' ' || keyword[i] > '~' {
+			return "", nil, ResponseCommandNotRecognized
+		}
+	}
+	if !utf8.ValidString(line) || strings.ContainsRune(line, 0) {
+		return "", nil, ResponseCommandSyntaxError
+	}
+	return strings.ToUpper(keyword), words[1:], 0
+}
+
+// resolveCommand finds the registered command named by a line read by readCommand. If the command can't be run
+// the response code to send is returned: 501 for a line that is too long or malformed, 500 for an unrecognized
+// command and 503 for a command registered without a handler
+func (srv *Server) resolveCommand(line string, err error) (Command, []string, int) {
+	if err == errLineTooLong {
+		return Command{}, nil, ResponseCommandSyntaxError
+	}
+	name, args, code := parseCommand(line)
+	if code != 0 {
+		return Command{}, nil, code
+	}
+	cmd, ok := srv.LookupCommand(name)
+	if !ok {
+		return Command{}, nil, ResponseCommandNotRecognized
+	}
+	if cmd.Handler == nil {
+		return Command{}, nil, ResponseCommandNotSupported
+	}
+	return cmd, args, 0
+}
+
+// protocolError sends the response to a malformed or unrecognized command, returning false
+// once the client has sent too many of them and should be disconnected
+func (c *Conn) protocolError(code int) bool {
+	c.protocolErrors++
+	max := c.server.MaxProtocolErrors
+	if max <= 0 {
+		max = DefaultMaxProtocolErrors
+	}
+	if c.protocolErrors > max {
+		c.WriteLine(ResponseText(ResponseServiceUnavailable))
+		c.bw.Flush()
+		return false
+	}
+	return c.WriteLine(ResponseText(code)) == nil
+}
@@ -0,0 +1,56 @@
+package nntp
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		line string
+		name string
+		args []string
+		code int
+	}{
+		{"group misc.test", "GROUP", []string{"misc.test"}, 0},
+		{"LISTGROUP\tmisc.test  1-10 ", "LISTGROUP", []string{"misc.test", "1-10"}, 0},
+		{"", "", nil, ResponseCommandNotRecognized},
+		{" \t ", "", nil, ResponseCommandNotRecognized},
+		{"GR\xffOUP misc.test", "", nil, ResponseCommandNotRecognized},
+		{"GROUP misc.\xfftest", "", nil, ResponseCommandSyntaxError},
+		{"GROUP misc\x00test", "", nil, ResponseCommandSyntaxError},
+		{"AUTHINFO USER jörg", "AUTHINFO", []string{"USER", "jörg"}, 0},
+	}
+	for _, test := range tests {
+		name, args, code := parseCommand(test.line)
+		if len(args) == 0 {
+			args = nil
+		}
+		if name != test.name || code != test.code || !reflect.DeepEqual(args, test.args) {
+			t.Errorf("parseCommand(%q) = %q, %q, %d, want %q, %q, %d", test.line, name, args, code, test.name, test.args, test.code)
+		}
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.MaxProtocolErrors = 3
+	srv.HandleCommand(Command{Name: "XRESERVED"})
+	c := dialTest(t, &srv)
+
+	c.send("\r\n")
+	c.expect("500")
+	c.send("XRESERVED\r\n")
+	c.expect("503")
+	c.send("GROUP " + strings.Repeat("x", MaxLineLength) + "\r\nDATE\r\n")
+	c.expect("501")
+	c.expect("111")
+	c.send("XUNKNOWN\r\n")
+	c.expect("500")
+	c.send("XUNKNOWN\r\n")
+	c.expect("400")
+}
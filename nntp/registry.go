@@ -0,0 +1,139 @@
+package nntp
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// HandlerFunc handles a command sent by a client, args holds the words following the command name. Handlers send
+// their own responses and return an error only when the connection can no longer be used
+type HandlerFunc func(c *Conn, args []string) error
+
+// Command describes a command handled by a Server
+type Command struct {
+	// Name is the command keyword clients send, it is matched case-insensitively
+	Name string
+	// Handler runs the command, a command registered without a handler is answered with 503
+	// so that unsupported extensions can be distinguished from unknown commands
+	Handler HandlerFunc
+
+	// Mode is the set of modes in which the command can be used, zero permits the command in every mode
+	Mode Mode
+	// Auth requires the client to authenticate before using the command, otherwise it is answered with 480
+	Auth bool
+	// Capability is a line advertised by CAPABILITIES while the command is available to the client. Capabilities
+	// whose availability depends on the backends, such as READER, POST and LIST, are derived by the server instead
+	Capability string
+	// Help describes the command's arguments in the response to HELP
+	Help string
+}
+
+// available reports whether a client may use the command in its current mode and authentication state
+func (cmd Command) available(c *Conn) bool {
+	if cmd.Handler == nil {
+		return false
+	}
+	if cmd.Mode != 0 && cmd.Mode&c.mode == 0 {
+		return false
+	}
+	return !cmd.Auth || c.identity != ""
+}
+
+// defaultCommands are the commands registered on every Server created by NewServer
+var defaultCommands = []Command{
+	{Name: "ARTICLE", Handler: ArticleHander, Mode: ModeReader, Help: "ARTICLE [message-ID|number]"},
+	{Name: "AUTHINFO", Handler: AuthinfoHandler, Help: "AUTHINFO USER name|PASS password|SASL mechanism [initial-response]"},
+	{Name: "BODY", Handler: BodyHandler, Mode: ModeReader, Help: "BODY [message-ID|number]"},
+	{Name: "CAPABILITIES", Handler: CapabilitiesHandler, Help: "CAPABILITIES [keyword]"},
+	{Name: "CHECK", Handler: CheckHandler, Mode: ModeTransit, Help: "CHECK message-ID"},
+	{Name: "DATE", Handler: DateHandler, Mode: ModeReader, Help: "DATE"},
+	{Name: "GROUP", Handler: GroupHandler, Mode: ModeReader, Help: "GROUP newsgroup"},
+	{Name: "HDR", Handler: HdrHandler, Mode: ModeReader, Capability: "HDR", Help: "HDR header [message-ID|range]"},
+	{Name: "XHDR", Handler: XhdrHandler, Mode: ModeReader, Help: "XHDR header [message-ID|range]"},
+	{Name: "HEAD", Handler: HeadHandler, Mode: ModeReader, Help: "HEAD [message-ID|number]"},
+	{Name: "HELP", Handler: HelpHandler, Help: "HELP"},
+	{Name: "IHAVE", Handler: IhaveHandler, Mode: ModeTransit, Help: "IHAVE message-ID"},
+	{Name: "LAST", Handler: LastHandler, Mode: ModeReader, Help: "LAST"},
+	{Name: "LIST", Handler: ListHandler, Help: "LIST [ACTIVE [wildmat]|ACTIVE.TIMES [wildmat]|DISTRIB.PATS|HEADERS [MSGID|RANGE]|NEWSGROUPS [wildmat]|OVERVIEW.FMT]"},
+	{Name: "LISTGROUP", Handler: ListgroupHandler, Mode: ModeReader, Help: "LISTGROUP [newsgroup [range]]"},
+	{Name: "MODE", Handler: ModeHandler, Help: "MODE READER|STREAM"},
+	{Name: "NEWGROUPS", Handler: NewgroupsHandler, Mode: ModeReader, Help: "NEWGROUPS [yy]yymmdd hhmmss [GMT]"},
+	{Name: "NEWNEWS", Handler: NewnewsHandler, Mode: ModeReader, Help: "NEWNEWS wildmat [yy]yymmdd hhmmss [GMT]"},
+	{Name: "NEXT", Handler: NextHandler, Mode: ModeReader, Help: "NEXT"},
+	{Name: "OVER", Handler: OverHandler, Mode: ModeReader, Help: "OVER [message-ID|range]"},
+	{Name: "XOVER", Handler: OverHandler, Mode: ModeReader, Help: "XOVER [range]"},
+	{Name: "POST", Handler: PostHandler, Mode: ModeReader, Help: "POST"},
+	{Name: "STAT", Handler: StatHandler, Mode: ModeReader, Help: "STAT [message-ID|number]"},
+	{Name: "QUIT", Handler: QuitHandler, Help: "QUIT"},
+	{Name: "STARTTLS", Handler: StarttlsHandler, Help: "STARTTLS"},
+	{Name: "TAKETHIS", Handler: TakethisHandler, Mode: ModeTransit, Help: "TAKETHIS message-ID"},
+}
+
+// commandRegistry is a concurrency-safe set of commands keyed by their upper-case name
+type commandRegistry struct {
+	mu       sync.RWMutex
+	commands map[string]Command
+}
+
+// newCommandRegistry creates a registry holding the default commands
+func newCommandRegistry() *commandRegistry {
+	r := &commandRegistry{commands: make(map[string]Command, len(defaultCommands))}
+	for _, cmd := range defaultCommands {
+		r.commands[cmd.Name] = cmd
+	}
+	return r
+}
+
+// HandleCommand registers a command, replacing any command already registered with the same name
+func (srv *Server) HandleCommand(cmd Command) {
+	cmd.Name = strings.ToUpper(cmd.Name)
+
+	srv.commands.mu.Lock()
+	defer srv.commands.mu.Unlock()
+	srv.commands.commands[cmd.Name] = cmd
+}
+
+// RemoveCommand unregisters a command, clients using it are answered as if the command doesn't exist
+func (srv *Server) RemoveCommand(name string) {
+	srv.commands.mu.Lock()
+	defer srv.commands.mu.Unlock()
+	delete(srv.commands.commands, strings.ToUpper(name))
+}
+
+// LookupCommand returns the command registered with the given name
+func (srv *Server) LookupCommand(name string) (Command, bool) {
+	srv.commands.mu.RLock()
+	defer srv.commands.mu.RUnlock()
+	cmd, ok := srv.commands.commands[strings.ToUpper(name)]
+	return cmd, ok
+}
+
+// Commands returns every registered command ordered by name
+func (srv *Server) Commands() []Command {
+	srv.commands.mu.RLock()
+	defer srv.commands.mu.RUnlock()
+
+	commands := make([]Command, 0, len(srv.commands.commands))
+	for _, cmd := range srv.commands.commands {
+		commands = append(commands, cmd)
+	}
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].Name < commands[j].Name
+	})
+	return commands
+}
+
+// WithCommand registers a command on the server, replacing any default command with the same name
+func WithCommand(cmd Command) ServerOption {
+	return func(srv *Server) {
+		srv.HandleCommand(cmd)
+	}
+}
+
+// WithoutCommand removes one of the default commands from the server
+func WithoutCommand(name string) ServerOption {
+	return func(srv *Server) {
+		srv.RemoveCommand(name)
+	}
+}
@@ -5,79 +5,131 @@ import "fmt"
 var Quiet bool = false
 
 const (
+	ResponseHelpFollows              = 100
 	ResponseCapabilitiesFollows      = 101
+	ResponseServerDate               = 111
 	ResponseServerReadyPosting       = 200
 	ResponseServerReadyNoPosting     = 201
+	ResponseStreamingPermitted       = 203
 	ResponseConnectionClosing        = 205
 	ResponseGroupSelected            = 211
 	ResponseGroupListFollows         = 215
-	ResponseGroupNotFound            = 511
-	ResponseGroupNotSelected         = 512
+	ResponseGroupNotFound            = 411
+	ResponseGroupNotSelected         = 412
 	ResponseArticleRetrievedHeadBody = 220
 	ResponseArticleRetrievedHead     = 221
 	ResponseArticleRetrievedBody     = 222
 	ResponseArticleRetrieved         = 223
+	ResponseOverviewFollows          = 224
+	ResponseHeadersFollow            = 225
+	ResponseNewArticlesFollow        = 230
+	ResponseNewGroupsFollow          = 231
 	ResponseArticleTransferred       = 235
+	ResponseSendArticleStream        = 238
+	ResponseArticleTransferredStream = 239
 	ResponseArticlePosted            = 240
+	ResponseAuthAccepted             = 281
 	ResponseTransferArticle          = 335
 	ResponsePostArticle              = 340
+	ResponsePasswordRequired         = 381
+	ResponseContinueTLS              = 382
+	ResponseSASLChallenge            = 383
+	ResponseServiceUnavailable       = 400
+	ResponseInternalFault            = 403
 	ResponseArticleNotSelected       = 420
 	ResponseArticleNoNext            = 421
 	ResponseArticleNoPrevious        = 422
 	ResponseArticleNotInGroup        = 423
 	ResponseArticleNotFound          = 430
+	ResponseArticleTryLater          = 431
 	ResponseArticleNotWanted         = 435
 	ResponseArticleTransferFailed    = 436
 	ResponseArticleRejected          = 437
+	ResponseArticleNotWantedStream   = 438
+	ResponseArticleRejectedStream    = 439
 	ResponsePostingNotAllowed        = 440
 	ResponsePostingFailed            = 441
+	ResponseAuthRequired             = 480
+	ResponseAuthFailed               = 481
+	ResponseAuthOutOfSequence        = 482
+	ResponseEncryptionRequired       = 483
 	ResponseCommandNotRecognized     = 500
 	ResponseCommandSyntaxError       = 501
+	ResponsePermissionDenied         = 502
 	ResponseCommandNotSupported      = 503
+	ResponseBase64Error              = 504
 )
 
 var responseText = map[int]string{
+	ResponseHelpFollows:              "%d help text follows",
 	ResponseCapabilitiesFollows:      "%d capability list follows (multi-line)",
+	ResponseServerDate:               "%d %s",
 	ResponseServerReadyPosting:       "%d server ready - posting allowed",
 	ResponseServerReadyNoPosting:     "%d server ready - no posting allowed",
+	ResponseStreamingPermitted:       "%d streaming permitted",
 	ResponseConnectionClosing:        "%d closing connection - goodbye!",
 	ResponseGroupSelected:            "%d %d %d %d %s group selected",
-	ResponseGroupListFollows:         "%d list of newsgroups follows",
+	ResponseGroupListFollows:         "%d information follows",
 	ResponseGroupNotFound:            "%d no such news group",
 	ResponseGroupNotSelected:         "%d no newsgroup has been selected",
 	ResponseArticleRetrievedHeadBody: "%d %d %s article retrieved - head and body follow",
 	ResponseArticleRetrievedHead:     "%d %d %s article retrieved - head follows",
 	ResponseArticleRetrievedBody:     "%d %d %s article retrieved - body follows",
 	ResponseArticleRetrieved:         "%d %d %s article retrieved - request text seperately",
+	ResponseOverviewFollows:          "%d overview information follows",
+	ResponseHeadersFollow:            "%d headers follow",
+	ResponseNewArticlesFollow:        "%d list of new articles follows",
+	ResponseNewGroupsFollow:          "%d list of new newsgroups follows",
 	ResponseArticleTransferred:       "%d article transferred ok",
+	ResponseSendArticleStream:        "%d %s send article",
+	ResponseArticleTransferredStream: "%d %s article transferred ok",
 	ResponseArticlePosted:            "%d article posted ok",
+	ResponseAuthAccepted:             "%d authentication accepted",
 	ResponseTransferArticle:          "%d send article to be transferred. End with <CR-LF>.<CR-LF>",
 	ResponsePostArticle:              "%d send article to be posted. End with <CR-LF>.<CR-LF>",
+	ResponsePasswordRequired:         "%d password required",
+	ResponseContinueTLS:              "%d continue with TLS negotiation",
+	ResponseSASLChallenge:            "%d %s",
+	ResponseServiceUnavailable:       "%d service temporarily unavailable",
+	ResponseInternalFault:            "%d internal fault",
 	ResponseArticleNotSelected:       "%d no current article has been selected",
 	ResponseArticleNoNext:            "%d no next article in this group",
 	ResponseArticleNoPrevious:        "%d no previous article in this group",
 	ResponseArticleNotInGroup:        "%d no such article number in this group",
 	ResponseArticleNotFound:          "%d no such article found",
+	ResponseArticleTryLater:          "%d %s try sending it again later",
 	ResponseArticleNotWanted:         "%d article not wanted - do not send it",
 	ResponseArticleTransferFailed:    "%d transfer failed - try again later",
 	ResponseArticleRejected:          "%d article rejected - do not try again",
+	ResponseArticleNotWantedStream:   "%d %s article not wanted",
+	ResponseArticleRejectedStream:    "%d %s article rejected - do not try again",
 	ResponsePostingNotAllowed:        "%d posting not allowed",
 	ResponsePostingFailed:            "%d posting failed",
+	ResponseAuthRequired:             "%d authentication required",
+	ResponseAuthFailed:               "%d authentication failed",
+	ResponseAuthOutOfSequence:        "%d authentication commands issued out of sequence",
+	ResponseEncryptionRequired:       "%d encryption required - use STARTTLS first",
 	ResponseCommandNotRecognized:     "%d command not recognized",
 	ResponseCommandSyntaxError:       "%d command syntax error",
+	ResponsePermissionDenied:         "%d access restriction or permission denied",
 	ResponseCommandNotSupported:      "%d command not supported",
+	ResponseBase64Error:              "%d base64 encoding error",
 }
 
 const (
 	quietStatusCode       = "%d"
 	quietGroupSelected    = "%d %d %d %d %s"
 	quietArticleRetrieved = "%d %d %s"
+	quietMessageID        = "%d %s"
 )
 
 var responseTextQuiet = map[int]string{
+	ResponseHelpFollows:              quietStatusCode,
 	ResponseCapabilitiesFollows:      quietStatusCode,
+	ResponseServerDate:               "%d %s",
 	ResponseServerReadyPosting:       quietStatusCode,
 	ResponseServerReadyNoPosting:     quietStatusCode,
+	ResponseStreamingPermitted:       quietStatusCode,
 	ResponseConnectionClosing:        quietStatusCode,
 	ResponseGroupSelected:            quietGroupSelected,
 	ResponseGroupListFollows:         quietStatusCode,
@@ -87,23 +139,44 @@ var responseTextQuiet = map[int]string{
 	ResponseArticleRetrievedHead:     quietArticleRetrieved,
 	ResponseArticleRetrievedBody:     quietArticleRetrieved,
 	ResponseArticleRetrieved:         quietArticleRetrieved,
+	ResponseOverviewFollows:          quietStatusCode,
+	ResponseHeadersFollow:            quietStatusCode,
+	ResponseNewArticlesFollow:        quietStatusCode,
+	ResponseNewGroupsFollow:          quietStatusCode,
 	ResponseArticleTransferred:       quietStatusCode,
+	ResponseSendArticleStream:        quietMessageID,
+	ResponseArticleTransferredStream: quietMessageID,
 	ResponseArticlePosted:            quietStatusCode,
+	ResponseAuthAccepted:             quietStatusCode,
 	ResponseTransferArticle:          quietStatusCode,
 	ResponsePostArticle:              quietStatusCode,
+	ResponsePasswordRequired:         quietStatusCode,
+	ResponseContinueTLS:              quietStatusCode,
+	ResponseSASLChallenge:            "%d %s",
+	ResponseServiceUnavailable:       quietStatusCode,
+	ResponseInternalFault:            quietStatusCode,
 	ResponseArticleNotSelected:       quietStatusCode,
 	ResponseArticleNoNext:            quietStatusCode,
 	ResponseArticleNoPrevious:        quietStatusCode,
 	ResponseArticleNotInGroup:        quietStatusCode,
 	ResponseArticleNotFound:          quietStatusCode,
+	ResponseArticleTryLater:          quietMessageID,
 	ResponseArticleNotWanted:         quietStatusCode,
 	ResponseArticleTransferFailed:    quietStatusCode,
 	ResponseArticleRejected:          quietStatusCode,
+	ResponseArticleNotWantedStream:   quietMessageID,
+	ResponseArticleRejectedStream:    quietMessageID,
 	ResponsePostingNotAllowed:        quietStatusCode,
 	ResponsePostingFailed:            quietStatusCode,
+	ResponseAuthRequired:             quietStatusCode,
+	ResponseAuthFailed:               quietStatusCode,
+	ResponseAuthOutOfSequence:        quietStatusCode,
+	ResponseEncryptionRequired:       quietStatusCode,
 	ResponseCommandNotRecognized:     quietStatusCode,
 	ResponseCommandSyntaxError:       quietStatusCode,
+	ResponsePermissionDenied:         quietStatusCode,
 	ResponseCommandNotSupported:      quietStatusCode,
+	ResponseBase64Error:              quietStatusCode,
 }
 
 func ResponseText(code int, param ...interface{}) string {
@@ -113,5 +186,14 @@ func ResponseText(code int, param ...interface{}) string {
 	} else {
 		format = responseText[code]
 	}
-	return fmt.Sprintf(format, append([]interface{}{code}, param)...)
+	return fmt.Sprintf(format, append([]interface{}{code}, param...)...)
+}
+
+// xhdrResponseText returns the initial response line to XHDR, which uses the 221 code given by section 2.6 of
+// RFC2980 rather than the 225 of HDR. It can't share the text of ResponseArticleRetrievedHead as it has no arguments
+func xhdrResponseText() string {
+	if Quiet {
+		return fmt.Sprintf(quietStatusCode, ResponseArticleRetrievedHead)
+	}
+	return fmt.Sprintf("%d header follows", ResponseArticleRetrievedHead)
 }
@@ -2,10 +2,12 @@ package nntp
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
+	"errors"
 	"log"
 	"net"
-	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,62 +19,437 @@ type Peer struct {
 }
 
 type Server struct {
-	Addr      string
+	Addr string
+	// TLSAddr is the address ListenAndServe accepts implicit TLS connections on alongside the plaintext listener,
+	// as is traditionally done on port 563. No implicit TLS listener is started if it is empty
+	TLSAddr   string
 	TLSConfig *tls.Config
 	Log       *log.Logger
 
-	storage Storage
-	auth    Auth
+	// RequireTLS refuses AUTHINFO on connections that have not been secured with TLS
+	RequireTLS bool
+	// ModeSwitching starts connections in transit mode, requiring newsreaders to send MODE READER before using
+	// reader commands, after which the transit commands are no longer available. Otherwise every command is
+	// available on every connection
+	ModeSwitching bool
+	// MaxConnections is the number of clients that can be connected at once, further clients are greeted with 400
+	// and disconnected. Zero places no limit on the number of connections
+	MaxConnections int
+	// HandshakeTimeout limits how long a client has to complete the TLS negotiation after STARTTLS,
+	// DefaultHandshakeTimeout is used if it is zero
+	HandshakeTimeout time.Duration
+	// IdleTimeout limits how long a client may wait between commands, ReadTimeout how long it has to finish sending
+	// a line, ArticleTimeout how long it has to send an article to POST, IHAVE or TAKETHIS, and WriteTimeout how long
+	// it has to accept each part of a response. A client that takes too long is sent 400 and disconnected, and the
+	// corresponding default is used for any timeout that is zero
+	IdleTimeout    time.Duration
+	ReadTimeout    time.Duration
+	ArticleTimeout time.Duration
+	WriteTimeout   time.Duration
+	// MaxProtocolErrors is the number of malformed or unrecognized commands a client may send before it is sent 400
+	// and disconnected, DefaultMaxProtocolErrors is used if it is zero
+	MaxProtocolErrors int
+	// Clock returns the current time used by DATE, NEWGROUPS and NEWNEWS, time.Now is used if it is nil
+	Clock func() time.Time
+
+	storage ContextStorage
+	auth    ContextAuth
 	filter  FilterFunc
 
 	peers []Peer
+
+	commands   *commandRegistry
+	middleware []Middleware
+	transfers  *transferSet
+	state      *serverState
+}
+
+// now returns the current time according to the server's clock
+func (srv *Server) now() time.Time {
+	if srv.Clock != nil {
+		return srv.Clock()
+	}
+	return time.Now()
+}
+
+// logf writes a message to the server's log if it has one
+func (srv *Server) logf(format string, args ...interface{}) {
+	if srv.Log != nil {
+		srv.Log.Printf(format, args...)
+	}
+}
+
+// DefaultHandshakeTimeout is the time clients have to complete the TLS negotiation
+// after STARTTLS if the server doesn't set a HandshakeTimeout
+const DefaultHandshakeTimeout = 30 * time.Second
+
+// handshakeTimeout returns the time clients have to complete the TLS negotiation after STARTTLS
+func (srv *Server) handshakeTimeout() time.Duration {
+	if srv.HandshakeTimeout > 0 {
+		return srv.HandshakeTimeout
+	}
+	return DefaultHandshakeTimeout
+}
+
+// serverState is a concurrency-safe record of the listeners and connections currently being served,
+// it allows them to be closed when the server shuts down
+type serverState struct {
+	// ctx is the parent of every connection's context, it is cancelled by Close or once Shutdown stops waiting
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu        sync.Mutex
+	closing   bool
+	listeners map[net.Listener]struct{}
+	conns     map[*Conn]struct{}
+}
+
+// addListener records a listener, returning false if the server is shutting down and it shouldn't be used
+func (s *serverState) addListener(ln net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closing {
+		return false
+	}
+	s.listeners[ln] = struct{}{}
+	return true
+}
+
+// removeListener removes a listener from the set once it has closed
+func (s *serverState) removeListener(ln net.Listener) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.listeners, ln)
+}
+
+// add adds a connection to the set, returning the number of connections including it
+func (s *serverState) add(c *Conn) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conns[c] = struct{}{}
+	return len(s.conns)
+}
+
+// remove removes a connection from the set once it has closed
+func (s *serverState) remove(c *Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.conns, c)
+}
+
+// transferSet is a concurrency-safe set of the message-ids of articles currently being transferred by peers,
+// it prevents the same article offered by several peers at once from being stored more than once
+type transferSet struct {
+	mu  sync.Mutex
+	ids map[string]struct{}
+}
+
+// claim adds a message-id to the set, returning false if it is already being transferred
+func (t *transferSet) claim(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.ids[id]; ok {
+		return false
+	}
+	t.ids[id] = struct{}{}
+	return true
 }
 
-func NewServer(addr string, config *tls.Config) (Server, error) {
+// release removes a message-id from the set once its transfer has completed
+func (t *transferSet) release(id string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.ids, id)
+}
+
+// has reports whether an article is currently being transferred
+func (t *transferSet) has(id string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, ok := t.ids[id]
+	return ok
+}
+
+// ServerOption configures the backends of a Server created by NewServer
+type ServerOption func(*Server)
+
+// WithStorage sets the storage backend holding the groups and articles served
+func WithStorage(storage Storage) ServerOption {
+	return func(srv *Server) {
+		srv.storage = NewContextStorage(storage)
+	}
+}
+
+// WithContextStorage sets a storage backend that is able to cancel requests made for clients that have gone away
+func WithContextStorage(storage ContextStorage) ServerOption {
+	return func(srv *Server) {
+		srv.storage = storage
+	}
+}
+
+// WithAuth sets the authentication backend used to authorize clients
+func WithAuth(auth Auth) ServerOption {
+	return func(srv *Server) {
+		srv.auth = NewContextAuth(auth)
+	}
+}
+
+// WithContextAuth sets an authentication backend that is able to cancel requests made for clients that have gone away
+func WithContextAuth(auth ContextAuth) ServerOption {
+	return func(srv *Server) {
+		srv.auth = auth
+	}
+}
+
+// WithFilter sets the function used to reject articles that are posted or transferred to the server
+func WithFilter(filter FilterFunc) ServerOption {
+	return func(srv *Server) {
+		srv.filter = filter
+	}
+}
+
+func NewServer(addr string, config *tls.Config, options ...ServerOption) (Server, error) {
+	ctx, cancel := context.WithCancel(context.Background())
 	srv := Server{
 		Addr:      addr,
 		TLSConfig: config,
+
+		commands:  newCommandRegistry(),
+		transfers: &transferSet{ids: make(map[string]struct{})},
+		state: &serverState{
+			ctx:       ctx,
+			cancel:    cancel,
+			listeners: make(map[net.Listener]struct{}),
+			conns:     make(map[*Conn]struct{}),
+		},
+	}
+	for _, option := range options {
+		option(&srv)
 	}
 	return srv, nil
 }
 
+// ErrNoCertificate is returned when implicit TLS is requested without a certificate to present to clients
+var ErrNoCertificate = errors.New("nntp: no TLS certificate configured")
+
+// WithImplicitTLS makes ListenAndServe also accept connections secured with TLS from the start on the given
+// address, using the server's TLSConfig
+func WithImplicitTLS(addr string) ServerOption {
+	return func(srv *Server) {
+		srv.TLSAddr = addr
+	}
+}
+
+// ListenAndServe listens for plaintext connections on Addr, and for implicit TLS connections on TLSAddr if it is set.
+// If either listener fails the server stops accepting connections on both, and the first error is returned once
+// they have been closed. Otherwise it returns ErrServerClosed once the server is shut down
 func (srv *Server) ListenAndServe() error {
-	ln, err := net.Listen("tcp", srv.Addr)
+	if srv.TLSAddr == "" {
+		return srv.listenAndServe(srv.Addr, nil)
+	}
+	config, err := srv.tlsConfig("", "")
+	if err != nil {
+		return err
+	}
+
+	errs := make(chan error, 2)
+	go func() {
+		errs <- srv.listenAndServe(srv.Addr, nil)
+	}()
+	go func() {
+		errs <- srv.listenAndServe(srv.TLSAddr, config)
+	}()
+	err = <-errs
+	srv.state.close()
+	<-errs
+	return err
+}
+
+// ListenAndServeTLS listens for implicit TLS connections on Addr. The certificate and key files are loaded in addition
+// to any certificates in TLSConfig, they can be empty if TLSConfig already holds the server's certificate
+func (srv *Server) ListenAndServeTLS(certFile string, keyFile string) error {
+	config, err := srv.tlsConfig(certFile, keyFile)
 	if err != nil {
 		return err
 	}
+	return srv.listenAndServe(srv.Addr, config)
+}
+
+// tlsConfig returns a copy of TLSConfig holding the given certificate, or an error if it would have no certificate
+func (srv *Server) tlsConfig(certFile string, keyFile string) (*tls.Config, error) {
+	config := &tls.Config{}
+	if srv.TLSConfig != nil {
+		config = srv.TLSConfig.Clone()
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+	if len(config.Certificates) == 0 && config.GetCertificate == nil {
+		return nil, ErrNoCertificate
+	}
+	return config, nil
+}
+
+// listenAndServe serves connections accepted on addr, which are secured with TLS from the start if config isn't nil
+func (srv *Server) listenAndServe(addr string, config *tls.Config) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	if config != nil {
+		ln = tls.NewListener(ln, config)
+	}
+	return srv.Serve(ln)
+}
+
+// Serve accepts connections on the listener and serves each of them on its own goroutine until the server is shut
+// down, when it returns ErrServerClosed. Connections accepted by a listener created with tls.NewListener are treated
+// as secure and are never offered STARTTLS
+func (srv *Server) Serve(ln net.Listener) error {
+	if !srv.state.addListener(ln) {
+		ln.Close()
+		return ErrServerClosed
+	}
+	defer srv.state.removeListener(ln)
+	defer ln.Close()
+
 	for {
 		rw, err := ln.Accept()
-		if err != nil && srv.Log != nil {
-			srv.Log.Println(err)
-			continue
+		if err != nil {
+			if srv.state.shuttingDown() {
+				return ErrServerClosed
+			}
+			if e, ok := err.(net.Error); ok && e.Temporary() {
+				if srv.Log != nil {
+					srv.Log.Println(err)
+				}
+				continue
+			}
+			return err
 		}
 		c := srv.NewConn(rw)
 		go srv.serve(c)
 	}
 }
 
-func (*Server) serve(c *Conn) {
-	s := bufio.NewScanner(c.br)
+// handshake completes the TLS negotiation with a client within the server's handshake timeout
+func (srv *Server) handshake(tlsConn *tls.Conn) error {
+	if err := tlsConn.SetDeadline(time.Now().Add(srv.handshakeTimeout())); err != nil {
+		return err
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	return tlsConn.SetDeadline(time.Time{})
+}
 
-	tlsStatus := c.isTLS
+// greet sends the initial response to a newly accepted connection as described in section 5.1 of RFC3977,
+// returning false if the client was refused service and should be disconnected
+func (srv *Server) greet(c *Conn, conns int) bool {
+	var code int
+	if srv.state.shuttingDown() || (srv.MaxConnections > 0 && conns > srv.MaxConnections) {
+		code = ResponseServiceUnavailable
+	} else if h, ok := backend(srv.auth).(HostAuth); ok && !h.HostAllowed(c.RemoteAddr()) {
+		code = ResponsePermissionDenied
+	} else if postingAllowed(c) {
+		code = ResponseServerReadyPosting
+	} else {
+		code = ResponseServerReadyNoPosting
+	}
 
-	for s.Scan() {
-		cmd_args := strings.Fields(s.Text())
-		cmd, args := cmd_args[0], cmd_args[1:]
-		cmd = strings.ToUpper(cmd)
-		if handler, ok := commandMap[cmd]; ok {
-			if err := handler(c, args); err != nil {
-				if e, ok := err.(net.Error); ok && !e.Temporary() {
-					c.server.Log.Printf(e.Error())
-					c.Close()
-				}
+	if err := c.WriteLine(ResponseText(code)); err != nil {
+		return false
+	}
+	if err := c.bw.Flush(); err != nil {
+		return false
+	}
+	return code == ResponseServerReadyPosting || code == ResponseServerReadyNoPosting
+}
+
+func (srv *Server) serve(c *Conn) {
+	defer c.Close()
+	defer c.cancel()
+	defer srv.state.remove(c)
+
+	// Implicit TLS connections are negotiated before the greeting so a client that never
+	// completes the handshake can't hold on to the connection
+	if tlsConn, ok := c.Conn.(*tls.Conn); ok {
+		if err := srv.handshake(tlsConn); err != nil {
+			return
+		}
+	}
+	if !srv.greet(c, srv.state.add(c)) {
+		return
+	}
+
+	// Commands are read directly from the connection's buffered reader rather than through a scanner so that
+	// handlers reading further input, such as the article following TAKETHIS, see any pipelined data.
+	// Responses are flushed by readCommand once all pipelined commands have been handled
+	for {
+		// Clients are told the server is going away once the command they were running has finished
+		if srv.state.shuttingDown() {
+			c.WriteLine(ResponseText(ResponseServiceUnavailable))
+			c.bw.Flush()
+			return
+		}
+		if err := c.flush(); err != nil {
+			return
+		}
+		if !c.setIdle() {
+			return
+		}
+		if err := c.waitForCommand(); err != nil {
+			if isTimeout(err) && c.setActive() {
+				c.sendTimeout()
+			}
+			return
+		}
+		line, err := c.readCommand()
+		if (err != nil && err != errLineTooLong) || !c.setActive() {
+			if isTimeout(err) {
+				c.sendTimeout()
+			}
+			return
+		}
+		command, args, code := srv.resolveCommand(line, err)
+		if code == ResponseCommandNotSupported {
+			if err := c.WriteLine(ResponseText(code)); err != nil {
+				return
+			}
+			continue
+		}
+		if code != 0 {
+			if !c.protocolError(code) {
+				return
 			}
+			continue
+		}
 
-			// Renew the scanner if the connection is upgraded to use TLS
-			if !tlsStatus && c.isTLS {
-				s = bufio.NewScanner(c.br)
-				tlsStatus = true
+		c.running = true
+		err = srv.chain(command)(c, args)
+		c.running = false
+		c.reader.stopWatching()
+		if isTimeout(err) || c.timedOut {
+			c.sendTimeout()
+			return
+		}
+		if err != nil {
+			if e, ok := err.(net.Error); ok && !e.Temporary() {
+				srv.logf("%v", e)
+				return
+			}
+			// The connection's context is only cancelled when the server gives up on it or the client has gone away
+			if c.ctx.Err() != nil {
+				return
+			}
+			// Any other error comes from a backend, which the client is told of so it isn't left waiting
+			srv.logf("%s from %s: %v", command.Name, c.RemoteAddr(), err)
+			if err := c.WriteLine(ResponseText(ResponseInternalFault)); err != nil {
+				return
 			}
 		}
 	}
@@ -80,18 +457,22 @@ func (*Server) serve(c *Conn) {
 
 func (srv *Server) NewConn(c net.Conn) *Conn {
 	_, isTLS := c.(*tls.Conn)
+	ctx, cancel := context.WithCancel(srv.state.ctx)
 
-	return &Conn{
-		c,
-		bufio.NewReader(c),
-		bufio.NewWriter(c),
+	conn := &Conn{
+		Conn: c,
 
-		isTLS,
+		isTLS: isTLS,
 
-		srv,
-		nil,
-		nil,
+		server: srv,
+		ctx:    ctx,
+		cancel: cancel,
+		mode:   srv.initialMode(),
 	}
+	conn.reader = newConnReader(conn)
+	conn.br = bufio.NewReader(conn.reader)
+	conn.bw = bufio.NewWriter(connWriter{conn})
+	return conn
 }
 
 func (srv *Server) PropagateNews() {
@@ -0,0 +1,40 @@
+package nntp
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestListenAndServeClosesListenersOnError(t *testing.T) {
+	// Occupy the plaintext address so only the implicit TLS listener can be created
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer busy.Close()
+
+	srv, err := NewServer(busy.Addr().String(), testTLSConfig(t), WithStorage(testStorage{}), WithImplicitTLS("127.0.0.1:0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil || err == ErrServerClosed {
+			t.Fatalf("ListenAndServe returned %v, want the error from listening on the busy address", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenAndServe didn't return after a listener failed")
+	}
+
+	srv.state.mu.Lock()
+	defer srv.state.mu.Unlock()
+	if len(srv.state.listeners) != 0 {
+		t.Errorf("%d listeners left open after ListenAndServe returned", len(srv.state.listeners))
+	}
+}
@@ -0,0 +1,130 @@
+package nntp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrServerClosed is returned by the Serve and ListenAndServe methods once Shutdown or Close has been called
+var ErrServerClosed = errors.New("nntp: Server closed")
+
+// shutdownPollInterval is how often Shutdown checks whether the connections still being served have become idle
+const shutdownPollInterval = 50 * time.Millisecond
+
+// shutdownWriteTimeout limits how long Shutdown waits to send 400 to an idle client before closing its connection
+const shutdownWriteTimeout = time.Second
+
+// Shutdown gracefully stops the server. It closes every listener, then sends 400 to each client as soon as it is
+// idle and closes its connection, so commands in progress such as an article being transferred with POST or IHAVE
+// are able to finish. Shutdown returns once every connection has been closed, or with the context's error if it
+// is done first, in which case the remaining connections are left open but their contexts are cancelled
+func (srv *Server) Shutdown(ctx context.Context) error {
+	err := srv.state.close()
+
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		if srv.state.closeIdle() {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			srv.state.cancel()
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Close immediately stops the server, closing every listener and connection without waiting for commands in progress
+func (srv *Server) Close() error {
+	err := srv.state.close()
+	srv.state.cancel()
+
+	srv.state.mu.Lock()
+	defer srv.state.mu.Unlock()
+	for c := range srv.state.conns {
+		c.closeNow()
+	}
+	return err
+}
+
+// shuttingDown reports whether Shutdown or Close has been called
+func (s *serverState) shuttingDown() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closing
+}
+
+// close stops new connections being served and closes every listener, returning the first error from closing them
+func (s *serverState) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closing = true
+	var err error
+	for ln := range s.listeners {
+		if cerr := ln.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		delete(s.listeners, ln)
+	}
+	return err
+}
+
+// closeIdle closes the connections of idle clients, returning true once there are no connections left
+func (s *serverState) closeIdle() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.conns {
+		c.closeIfIdle()
+	}
+	return len(s.conns) == 0
+}
+
+// setIdle marks the connection as waiting for the client's next command, returning false if it has been closed.
+// A connection with pipelined commands waiting to be read is left active as the next read won't block
+func (c *Conn) setIdle() bool {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.closing {
+		return false
+	}
+	c.idle = c.br.Buffered() == 0
+	return true
+}
+
+// setActive marks the connection as running a command, returning false if it was closed while idle
+func (c *Conn) setActive() bool {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if c.closing {
+		return false
+	}
+	c.idle = false
+	return true
+}
+
+// closeIfIdle sends 400 to the client and closes the connection if it is waiting for a command. The response is
+// written directly to the connection since the goroutine serving it may be blocked reading from the buffered reader
+func (c *Conn) closeIfIdle() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	if !c.idle || c.closing {
+		return
+	}
+	c.closing = true
+	c.Conn.SetWriteDeadline(time.Now().Add(shutdownWriteTimeout))
+	io.WriteString(c.Conn, ResponseText(ResponseServiceUnavailable)+"\r\n")
+	c.Conn.Close()
+}
+
+// closeNow closes the connection regardless of what the client is doing
+func (c *Conn) closeNow() {
+	c.stateMu.Lock()
+	defer c.stateMu.Unlock()
+	c.closing = true
+	c.Conn.Close()
+}
@@ -0,0 +1,109 @@
+package nntp
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// serveTest serves srv on a local port and returns a connected client along with the result of Serve
+func serveTest(t *testing.T, srv *Server) (*testClient, <-chan error) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ln)
+	}()
+	t.Cleanup(func() { srv.Close() })
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	c := &testClient{t: t, Conn: conn, r: bufio.NewReader(conn)}
+	c.expect("20")
+	return c, served
+}
+
+func TestShutdownIdleClient(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, served := serveTest(t, &srv)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown = %v", err)
+	}
+	c.expect("400")
+	c.expectClosed()
+
+	select {
+	case err := <-served:
+		if err != ErrServerClosed {
+			t.Errorf("Serve returned %v, want ErrServerClosed", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve didn't return after Shutdown")
+	}
+}
+
+func TestShutdownWaitsForTransfer(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}), WithAuth(feedAuth{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := serveTest(t, &srv)
+	c.send("IHAVE <inflight@example.com>\r\n")
+	c.expect("335")
+
+	shutdown := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		shutdown <- srv.Shutdown(ctx)
+	}()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown returned %v while an article was being transferred", err)
+	case <-time.After(4 * shutdownPollInterval):
+	}
+
+	c.send("Message-ID: <inflight@example.com>\r\nNewsgroups: test.group\r\nSubject: Hello\r\n\r\nBody\r\n.\r\n")
+	c.expect("235")
+	c.expect("400")
+	c.expectClosed()
+
+	select {
+	case err := <-shutdown:
+		if err != nil {
+			t.Errorf("Shutdown = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Shutdown didn't return once the transfer finished")
+	}
+}
+
+func TestShutdownContextExpired(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}), WithAuth(feedAuth{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := serveTest(t, &srv)
+	c.send("IHAVE <stalled@example.com>\r\n")
+	c.expect("335")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*shutdownPollInterval)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Shutdown = %v, want context.DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,105 @@
+package nntp
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStarttls(t *testing.T) {
+	c := dialTest(t, newTLSTestServer(t))
+	if !hasCap(c.capabilities(), "STARTTLS") {
+		t.Fatal("STARTTLS not advertised on a plaintext connection")
+	}
+
+	c.send("STARTTLS\r\n")
+	c.expect("382")
+	c.starttls()
+
+	caps := c.capabilities()
+	if hasCap(caps, "STARTTLS") {
+		t.Error("STARTTLS advertised after TLS was negotiated")
+	}
+	if !hasCap(caps, "AUTHINFO USER") {
+		t.Error("AUTHINFO not advertised after TLS was negotiated")
+	}
+
+	c.send("STARTTLS\r\n")
+	c.expect("502")
+}
+
+func TestStarttlsDiscardsPlaintext(t *testing.T) {
+	c := dialTest(t, newTLSTestServer(t))
+
+	// QUIT is pipelined in plaintext and must not be run once the connection is secured
+	c.send("STARTTLS\r\nQUIT\r\n")
+	c.expect("382")
+	c.starttls()
+
+	c.send("CAPABILITIES\r\n")
+	if line := c.expect(""); !strings.HasPrefix(line, "101") {
+		t.Fatalf("got response %q to CAPABILITIES, the plaintext QUIT was run on the secured connection", strings.TrimSpace(line))
+	}
+}
+
+func TestStarttlsAfterAuthentication(t *testing.T) {
+	c := dialTest(t, newTLSTestServer(t))
+	c.send("AUTHINFO USER reader\r\n")
+	c.expect("381")
+	c.send("AUTHINFO PASS secret\r\n")
+	c.expect("281")
+
+	if hasCap(c.capabilities(), "STARTTLS") {
+		t.Error("STARTTLS advertised after authentication")
+	}
+	c.send("STARTTLS\r\n")
+	c.expect("502")
+}
+
+func TestStarttlsResetsSession(t *testing.T) {
+	c := dialTest(t, newTLSTestServer(t))
+	c.send("GROUP test.group\r\n")
+	c.expect("211")
+
+	c.send("STARTTLS\r\n")
+	c.expect("382")
+	c.starttls()
+
+	c.send("LISTGROUP\r\n")
+	c.expect("412")
+}
+
+func TestStarttlsNotConfigured(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := dialTest(t, &srv)
+	if hasCap(c.capabilities(), "STARTTLS") {
+		t.Error("STARTTLS advertised without a TLS configuration")
+	}
+	c.send("STARTTLS\r\n")
+	c.expect("503")
+}
+
+func TestStarttlsHandshakeTimeout(t *testing.T) {
+	srv := newTLSTestServer(t)
+	srv.HandshakeTimeout = 50 * time.Millisecond
+	c := dialTest(t, srv)
+
+	c.send("STARTTLS\r\n")
+	c.expect("382")
+
+	// The client never starts the negotiation, so the server must give up and close the connection
+	c.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 512)
+	for {
+		if _, err := c.Read(buf); err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				t.Fatal("connection still open after the handshake timeout")
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Chemiseblanc/gonews/nntp"
+)
+
+// ArrivalIndex is a concurrency-safe in-memory index of articles ordered by arrival time. Storage backends record
+// an entry for every article accepted by PostArticle and can embed it to implement the nntp.ArrivalStorage interface
+type ArrivalIndex struct {
+	mu       sync.RWMutex
+	arrivals []nntp.Arrival
+}
+
+// NewArrivalIndex creates an empty arrival index
+func NewArrivalIndex() *ArrivalIndex {
+	return &ArrivalIndex{}
+}
+
+// Add records the arrival of an article, keeping the index ordered even if arrivals are recorded out of order
+func (idx *ArrivalIndex) Add(a nntp.Arrival) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	i := sort.Search(len(idx.arrivals), func(i int) bool {
+		return idx.arrivals[i].Time.After(a.Time)
+	})
+	idx.arrivals = append(idx.arrivals, nntp.Arrival{})
+	copy(idx.arrivals[i+1:], idx.arrivals[i:])
+	idx.arrivals[i] = a
+}
+
+// ArticlesSince returns the articles that arrived at or after the given time in order of arrival
+func (idx *ArrivalIndex) ArticlesSince(since time.Time) ([]nntp.Arrival, error) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	i := sort.Search(len(idx.arrivals), func(i int) bool {
+		return !idx.arrivals[i].Time.Before(since)
+	})
+	return append([]nntp.Arrival(nil), idx.arrivals[i:]...), nil
+}
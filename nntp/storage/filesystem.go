@@ -2,84 +2,811 @@ package storage
 
 import (
 	"bufio"
-	"crypto/sha1"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"net/mail"
+	"net/textproto"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/Chemiseblanc/gonews/nntp"
 )
 
-type LegacyFileSystem struct{}
+var (
+	// ErrDuplicateArticle is returned when posting an article whose message-id is already in the history file
+	ErrDuplicateArticle = errors.New("storage: duplicate article")
+	// ErrNoMessageID is returned when posting an article without a Message-ID header
+	ErrNoMessageID = errors.New("storage: article has no message-id")
+	// ErrNoGroups is returned when none of the groups an article was posted to are carried by the server
+	ErrNoGroups = errors.New("storage: article was not posted to any group carried by this server")
+)
+
+// LegacyFileSystem is a storage backend compatible with the file layout used by INN's tradspool storage method.
+// Under Root it reads the active, newsgroups, active.times, history and tradspool.map files,
+// with articles stored one per file under articles/ in a directory named after each group.
+//
+// The history file is indexed the first time an article is looked up or posted. The overview database is built the
+// first time it is needed by reading every article in the spool that the history file refers to, which takes a while
+// for a large spool. After that both are kept up to date by PostArticle, so other programs must not add articles
+// while the backend is in use
+type LegacyFileSystem struct {
+	Root string
+	// Hostname is used as the path identity in the Xref header of posted articles
+	Hostname string
+
+	mu sync.Mutex
+
+	// history maps the hash of each message-id in the history file to the storage token of its article,
+	// it is nil until the history file has been indexed
+	loadMu    sync.Mutex
+	historyMu sync.RWMutex
+	history   map[string]string
+	// overview is nil until it has been built from the spool
+	overviewMu sync.Mutex
+	overview   *OverviewDB
+}
 
-func (l *LegacyFileSystem) HasArticle(id string) (bool, error) {
-	article, err := l.GetArticleByID(id)
+// NewLegacyFileSystem creates a tradspool backend for the spool at root, such as /var/spool/news
+func NewLegacyFileSystem(root string) *LegacyFileSystem {
+	hostname, err := os.Hostname()
 	if err != nil {
-		return false, err
+		hostname = "localhost"
+	}
+	return &LegacyFileSystem{
+		Root:     root,
+		Hostname: hostname,
 	}
+}
 
-	if article != nil {
-		return true, nil
-	} else {
-		return false, nil
+func (l *LegacyFileSystem) path(name string) string {
+	return filepath.Join(l.Root, name)
+}
+
+// articlePath returns the location of an article in the spool, with each component of the group name as a directory
+func (l *LegacyFileSystem) articlePath(group string, number uint) string {
+	return filepath.Join(l.Root, "articles", filepath.Join(strings.Split(group, ".")...), strconv.FormatUint(uint64(number), 10))
+}
+
+// readFields reads a whitespace separated file, skipping blank lines, and calls fn with the fields of each line
+// until it returns false. A missing file is treated as empty
+func readFields(path string, fn func([]string) bool) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if !fn(fields) {
+			break
+		}
 	}
+	return scanner.Err()
 }
 
-func (*LegacyFileSystem) GetGroup(group string) (*nntp.Group, error) {
-	active, err := os.Open("/var/spool/news/active")
+// writeFileAtomic replaces the contents of a file by writing a temporary file and renaming it over the original
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path))
 	if err != nil {
-		return nil, err
+		return err
 	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
 
-	found := false
-	var description string
-	var min uint
-	var max uint
-	var flag string
+// activeEntry is a line of the active file, which is laid out as "name high low flag"
+type activeEntry struct {
+	name string
+	high uint
+	low  uint
+	flag string
+}
 
-	scanner := bufio.NewScanner(active)
-	for scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
+func (l *LegacyFileSystem) readActive() ([]activeEntry, error) {
+	var entries []activeEntry
+	err := readFields(l.path("active"), func(fields []string) bool {
 		if len(fields) < 4 {
-			return nil, nil
+			return true
 		}
-		if fields[0] == group {
-			found = true
-			min, _ := strconv.ParseUint(fields[1], 10, 0)
-			max, _ := strconv.ParseUint(fields[2], 10, 0)
-			flag = fields[3]
-			break
+		high, _ := strconv.ParseUint(fields[1], 10, 0)
+		low, _ := strconv.ParseUint(fields[2], 10, 0)
+		entries = append(entries, activeEntry{fields[0], uint(high), uint(low), fields[3]})
+		return true
+	})
+	return entries, err
+}
+
+func (l *LegacyFileSystem) writeActive(entries []activeEntry) error {
+	var b bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s %010d %010d %s\n", e.name, e.high, e.low, e.flag)
+	}
+	return writeFileAtomic(l.path("active"), b.Bytes())
+}
+
+// descriptions reads the group descriptions held in the newsgroups file
+func (l *LegacyFileSystem) descriptions() (map[string]string, error) {
+	descriptions := make(map[string]string)
+	f, err := os.Open(l.path("newsgroups"))
+	if os.IsNotExist(err) {
+		return descriptions, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(fields) == 2 {
+			descriptions[fields[0]] = strings.TrimLeft(fields[1], "\t")
 		}
+	}
+	return descriptions, scanner.Err()
+}
 
+func (e activeEntry) group(description string, times groupTime) nntp.Group {
+	g := nntp.Group{
+		Name:        e.name,
+		Description: description,
+		Min:         e.low,
+		Max:         e.high,
+		Flag:        e.flag,
+		Created:     times.created,
+		Creator:     times.creator,
 	}
-	return nil, nil
+	if e.high >= e.low {
+		g.Count = e.high - e.low + 1
+	}
+	return g
 }
 
-func (*LegacyFileSystem) PostArticle(article nntp.Article) error {
+// Group returns the group with the given name from the active file, or nil if the server doesn't carry it
+func (l *LegacyFileSystem) Group(name string) *nntp.Group {
+	entries, err := l.readActive()
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		if e.name == name {
+			descriptions, _ := l.descriptions()
+			times, _ := l.activeTimes()
+			g := e.group(descriptions[name], times[name])
+			return &g
+		}
+	}
 	return nil
 }
 
-func (*LegacyFileSystem) GetArticleByID(id string) (*nntp.Article, error) {
-	history, err := os.Open("/var/spool/news/history")
+// Groups returns every group in the active file
+func (l *LegacyFileSystem) Groups() ([]nntp.Group, error) {
+	entries, err := l.readActive()
 	if err != nil {
 		return nil, err
 	}
-	hash := fmt.Sprintf("%X", sha1.Sum([]byte(id)))
+	descriptions, err := l.descriptions()
+	if err != nil {
+		return nil, err
+	}
+	times, err := l.activeTimes()
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]nntp.Group, 0, len(entries))
+	for _, e := range entries {
+		groups = append(groups, e.group(descriptions[e.name], times[e.name]))
+	}
+	return groups, nil
+}
 
-	scanner := bufio.NewScanner(history)
-	for scanner.Scan() {
-		fields := strings.Fields(scanner.Text())
+// groupTime is a line of the active.times file, which is laid out as "name seconds creator"
+type groupTime struct {
+	created time.Time
+	creator string
+}
+
+// activeTimes reads the creation time and creator of each group from the active.times file
+func (l *LegacyFileSystem) activeTimes() (map[string]groupTime, error) {
+	times := make(map[string]groupTime)
+	err := readFields(l.path("active.times"), func(fields []string) bool {
+		if len(fields) < 3 {
+			return true
+		}
+		if seconds, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			times[fields[0]] = groupTime{time.Unix(seconds, 0), fields[2]}
+		}
+		return true
+	})
+	return times, err
+}
+
+// CreationTimes reports that groups record their creation time and creator from the active.times file
+func (l *LegacyFileSystem) CreationTimes() bool {
+	return true
+}
+
+// appendLine appends a line to a file, creating it if it doesn't exist
+func appendLine(path string, format string, args ...interface{}) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, format+"\n", args...)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// AddGroup creates an empty group by adding it to the active file, recording its creation time and creator in
+// active.times and its description in the newsgroups file. The creation time is set to the current time unless
+// g specifies one, and an empty creator is recorded as "news"
+func (l *LegacyFileSystem) AddGroup(g nntp.Group) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries, err := l.readActive()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.name == g.Name {
+			return fmt.Errorf("storage: group %s already exists", g.Name)
+		}
+	}
+	if g.Flag == "" {
+		g.Flag = "y"
+	}
+	if g.Created.IsZero() {
+		g.Created = time.Now()
+	}
+	if g.Creator == "" {
+		g.Creator = "news"
+	}
+
+	if err := l.writeActive(append(entries, activeEntry{g.Name, 0, 1, g.Flag})); err != nil {
+		return err
+	}
+	if err := appendLine(l.path("active.times"), "%s %d %s", g.Name, g.Created.Unix(), g.Creator); err != nil {
+		return err
+	}
+	if g.Description != "" {
+		return appendLine(l.path("newsgroups"), "%s\t%s", g.Name, g.Description)
+	}
+	return nil
+}
+
+// hashMessageID computes the history file key of a message-id the same way as INN,
+// the domain part is case insensitive so it is lowercased before hashing
+func hashMessageID(id string) string {
+	if at := strings.IndexByte(id, '@'); at >= 0 {
+		id = id[:at] + strings.ToLower(id[at:])
+	}
+	sum := md5.Sum([]byte(id))
+	return "[" + strings.ToUpper(hex.EncodeToString(sum[:])) + "]"
+}
+
+// tradspoolType is the storage method identifier of tradspool in INN storage tokens
+const tradspoolType = 0x05
+
+// encodeToken produces the INN storage token of a tradspool article, which holds the group's number in
+// tradspool.map and the article number, both big endian, followed by padding
+func encodeToken(groupNumber uint32, number uint32) string {
+	var token [18]byte
+	token[0] = tradspoolType
+	token[2], token[3], token[4], token[5] = byte(groupNumber>>24), byte(groupNumber>>16), byte(groupNumber>>8), byte(groupNumber)
+	token[6], token[7], token[8], token[9] = byte(number>>24), byte(number>>16), byte(number>>8), byte(number)
+	return "@" + strings.ToUpper(hex.EncodeToString(token[:])) + "@"
+}
+
+// decodeToken extracts the group and article numbers from a tradspool storage token
+func decodeToken(token string) (uint32, uint32, bool) {
+	if len(token) != 38 || token[0] != '@' || token[37] != '@' {
+		return 0, 0, false
+	}
+	b, err := hex.DecodeString(token[1:37])
+	if err != nil || b[0] != tradspoolType {
+		return 0, 0, false
+	}
+	groupNumber := uint32(b[2])<<24 | uint32(b[3])<<16 | uint32(b[4])<<8 | uint32(b[5])
+	number := uint32(b[6])<<24 | uint32(b[7])<<16 | uint32(b[8])<<8 | uint32(b[9])
+	return groupNumber, number, true
+}
+
+// readMap reads tradspool.map which assigns each group a number used in storage tokens
+func (l *LegacyFileSystem) readMap() (map[string]uint32, error) {
+	groups := make(map[string]uint32)
+	err := readFields(l.path("tradspool.map"), func(fields []string) bool {
+		if len(fields) >= 2 {
+			if n, err := strconv.ParseUint(fields[1], 10, 32); err == nil {
+				groups[fields[0]] = uint32(n)
+			}
+		}
+		return true
+	})
+	return groups, err
+}
+
+// loadHistory indexes the history file the first time it is needed. The index is only kept once the whole file has
+// been read, so after an error the next call tries again
+func (l *LegacyFileSystem) loadHistory() error {
+	l.loadMu.Lock()
+	defer l.loadMu.Unlock()
+	if l.history != nil {
+		return nil
+	}
+
+	history := make(map[string]string)
+	err := readFields(l.path("history"), func(fields []string) bool {
+		// Entries of expired articles are kept without a token so that they continue to be rejected as duplicates
+		var token string
+		if len(fields) >= 3 {
+			token = fields[2]
+		}
+		history[fields[0]] = token
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	l.historyMu.Lock()
+	l.history = history
+	l.historyMu.Unlock()
+	return nil
+}
+
+// loadOverview builds the overview database from the articles the history file refers to the first time it is
+// needed. Like the history index it is only kept once it has been built without errors
+func (l *LegacyFileSystem) loadOverview() (*OverviewDB, error) {
+	if err := l.loadHistory(); err != nil {
+		return nil, err
+	}
+	l.overviewMu.Lock()
+	defer l.overviewMu.Unlock()
+	if l.overview != nil {
+		return l.overview, nil
+	}
+
+	tradspoolMap, err := l.readMap()
+	if err != nil {
+		return nil, err
+	}
+	names := groupNames(tradspoolMap)
+
+	l.historyMu.RLock()
+	tokens := make([]string, 0, len(l.history))
+	for _, token := range l.history {
+		tokens = append(tokens, token)
+	}
+	l.historyMu.RUnlock()
+
+	overview := NewOverviewDB()
+	for _, token := range tokens {
+		path, ok := l.tokenPath(token, names)
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		header, body, err := parseArticle(data)
+		if err != nil {
+			continue
+		}
+		overview.Add(nntp.NewOverview(0, header, body), xrefNumbers(header.Get("Xref")))
+	}
+	l.overview = overview
+	return overview, nil
+}
+
+// groupNames reverses tradspool.map, mapping the number used in storage tokens to the name of each group
+func groupNames(tradspoolMap map[string]uint32) map[uint32]string {
+	names := make(map[uint32]string, len(tradspoolMap))
+	for name, n := range tradspoolMap {
+		names[n] = name
+	}
+	return names
+}
+
+// tokenPath returns the location in the spool of the article a storage token refers to,
+// names maps the group numbers used in tokens to group names as returned by groupNames
+func (l *LegacyFileSystem) tokenPath(token string, names map[uint32]string) (string, bool) {
+	groupNumber, number, ok := decodeToken(token)
+	if !ok {
+		return "", false
+	}
+	name, ok := names[groupNumber]
+	if !ok {
+		return "", false
+	}
+	return l.articlePath(name, uint(number)), true
+}
+
+// xrefNumbers returns the number an article was assigned in each group listed by its Xref header
+func xrefNumbers(xref string) map[string]uint {
+	numbers := make(map[string]uint)
+	fields := strings.Fields(xref)
+	if len(fields) > 0 {
+		// The first field is the name of the server the numbers were assigned by
+		fields = fields[1:]
+	}
+	for _, ref := range fields {
+		if i := strings.LastIndexByte(ref, ':'); i > 0 {
+			if n, err := strconv.ParseUint(ref[i+1:], 10, 0); err == nil {
+				numbers[ref[:i]] = uint(n)
+			}
+		}
+	}
+	return numbers
+}
+
+// lookupHistory finds a message-id in the history file, returning the storage token of the article
+// which is empty if the article has expired
+func (l *LegacyFileSystem) lookupHistory(id string) (string, bool, error) {
+	if err := l.loadHistory(); err != nil {
+		return "", false, err
+	}
+	l.historyMu.RLock()
+	defer l.historyMu.RUnlock()
+	token, found := l.history[hashMessageID(id)]
+	return token, found, nil
+}
+
+// OverviewFormat returns the fields held in the overview database
+func (l *LegacyFileSystem) OverviewFormat() []string {
+	return nntp.DefaultOverviewFormat
+}
+
+// OverviewByGroup returns the overview entries of the articles in a group numbered between low and high inclusive
+func (l *LegacyFileSystem) OverviewByGroup(group nntp.Group, low uint, high uint) ([]nntp.Overview, error) {
+	overview, err := l.loadOverview()
+	if err != nil {
+		return nil, err
+	}
+	return overview.OverviewByGroup(group, low, high)
+}
+
+// OverviewByID returns the overview entry of an article, or nil if there is no such article
+func (l *LegacyFileSystem) OverviewByID(id string) (*nntp.Overview, error) {
+	overview, err := l.loadOverview()
+	if err != nil {
+		return nil, err
+	}
+	return overview.OverviewByID(id)
+}
+
+// ArticlesSince returns the articles whose arrival time recorded in the history file is at or after the given time,
+// the groups of each article are read from the Xref header of its spool file
+func (l *LegacyFileSystem) ArticlesSince(since time.Time) ([]nntp.Arrival, error) {
+	type entry struct {
+		hash    string
+		arrived time.Time
+		token   string
+	}
+	var entries []entry
+	err := readFields(l.path("history"), func(fields []string) bool {
 		if len(fields) < 3 {
-			return nil, nil
+			return true
 		}
-		if fields[0] == hash {
+		seconds, err := strconv.ParseInt(strings.SplitN(fields[1], "~", 2)[0], 10, 64)
+		if err != nil || time.Unix(seconds, 0).Before(since) {
+			return true
+		}
+		entries = append(entries, entry{fields[0], time.Unix(seconds, 0), fields[2]})
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].arrived.Before(entries[j].arrived)
+	})
+
+	tradspoolMap, err := l.readMap()
+	if err != nil {
+		return nil, err
+	}
+	names := groupNames(tradspoolMap)
+
+	arrivals := make([]nntp.Arrival, 0, len(entries))
+	for _, e := range entries {
+		groupNumber, number, ok := decodeToken(e.token)
+		if !ok {
+			continue
+		}
+		name, ok := names[groupNumber]
+		if !ok {
+			continue
+		}
+		article, err := readArticle(l.articlePath(name, uint(number)))
+		if err != nil {
+			return nil, err
+		}
+		if article == nil {
+			continue
+		}
+		arrival := nntp.Arrival{MessageID: article.MessageID(), Time: e.arrived}
+		// The first field of the Xref header is the name of the server the numbers were assigned by
+		xref := strings.Fields(article.Get("Xref"))
+		if len(xref) > 0 {
+			xref = xref[1:]
+		}
+		for _, ref := range xref {
+			if i := strings.LastIndexByte(ref, ':'); i > 0 {
+				arrival.Newsgroups = append(arrival.Newsgroups, ref[:i])
+			}
+		}
+		arrivals = append(arrivals, arrival)
+	}
+	return arrivals, nil
+}
+
+// HasArticle reports whether the history file records an article with the given message-id
+func (l *LegacyFileSystem) HasArticle(id string) bool {
+	_, found, err := l.lookupHistory(id)
+	return err == nil && found
+}
+
+// readArticle parses an article file, returning nil if it doesn't exist
+func readArticle(path string) (*nntp.Article, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	header, body, err := parseArticle(data)
+	if err != nil {
+		return nil, err
+	}
+	return &nntp.Article{
+		MIMEHeader: header,
+		Body:       bytes.NewReader(body),
+	}, nil
+}
+
+// parseArticle splits the contents of an article file into its header and body
+func parseArticle(data []byte) (textproto.MIMEHeader, []byte, error) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, nil, err
+	}
+	body, err := io.ReadAll(r)
+	return header, body, err
+}
+
+// ArticleByID returns the article with the given message-id by resolving its storage token from the history file
+func (l *LegacyFileSystem) ArticleByID(id string) (*nntp.Article, error) {
+	token, _, err := l.lookupHistory(id)
+	if err != nil || token == "" {
+		return nil, err
+	}
+	if _, _, ok := decodeToken(token); !ok {
+		return nil, fmt.Errorf("storage: %s has an unsupported storage token %s", id, token)
+	}
+
+	tradspoolMap, err := l.readMap()
+	if err != nil {
+		return nil, err
+	}
+	path, ok := l.tokenPath(token, groupNames(tradspoolMap))
+	if !ok {
+		return nil, fmt.Errorf("storage: %s refers to a group missing from tradspool.map", id)
+	}
+	return readArticle(path)
+}
+
+// ArticleByGroup returns the article with the given number in a group, or nil if there is no such article
+func (l *LegacyFileSystem) ArticleByGroup(group nntp.Group, number uint) (*nntp.Article, error) {
+	return readArticle(l.articlePath(group.Name, number))
+}
+
+// ArticleNumbers returns the numbers of the articles in a group between low and high inclusive in ascending order
+// by listing the group's spool directory
+func (l *LegacyFileSystem) ArticleNumbers(group nntp.Group, low uint, high uint) ([]uint, error) {
+	entries, err := os.ReadDir(filepath.Dir(l.articlePath(group.Name, 0)))
+	if os.IsNotExist(err) {
+		return []uint{}, nil
+	} else if err != nil {
+		return nil, err
+	}
 
+	numbers := make([]uint, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		// Subgroups are stored as directories alongside the articles, so only numeric file names are articles
+		number, err := strconv.ParseUint(e.Name(), 10, 0)
+		if err != nil {
+			continue
+		}
+		if uint(number) >= low && uint(number) <= high {
+			numbers = append(numbers, uint(number))
 		}
 	}
+	sort.Slice(numbers, func(i, j int) bool {
+		return numbers[i] < numbers[j]
+	})
+	return numbers, nil
+}
 
+// writeHeader writes article headers in the native line ending format of the spool
+func writeHeader(w io.Writer, header textproto.MIMEHeader) error {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			if _, err := fmt.Fprintf(w, "%s: %s\n", k, v); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
 }
 
-func (*LegacyFileSystem) GetArticleByGroup(group nntp.Group, number uint) (*nntp.Article, error) {
+// PostArticle files an article in each group it was posted to that the server carries. Article numbers are reserved
+// by rewriting the active file, the article is written to the spool, and then its history entry is appended
+func (l *LegacyFileSystem) PostArticle(article nntp.Article) error {
+	id := article.MessageID()
+	if id == "" {
+		return ErrNoMessageID
+	}
 
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, found, err := l.lookupHistory(id); err != nil {
+		return err
+	} else if found {
+		return ErrDuplicateArticle
+	}
+
+	entries, err := l.readActive()
+	if err != nil {
+		return err
+	}
+	var groups []string
+	numbers := make(map[string]uint)
+	for _, name := range strings.Split(article.Get("Newsgroups"), ",") {
+		name = strings.TrimSpace(name)
+		if _, ok := numbers[name]; ok {
+			continue
+		}
+		for i := range entries {
+			if entries[i].name == name && entries[i].flag != "x" {
+				entries[i].high++
+				if entries[i].low == 0 {
+					entries[i].low = 1
+				}
+				numbers[name] = entries[i].high
+				groups = append(groups, name)
+				break
+			}
+		}
+	}
+	if len(groups) == 0 {
+		return ErrNoGroups
+	}
+
+	tradspoolMap, err := l.readMap()
+	if err != nil {
+		return err
+	}
+	if _, ok := tradspoolMap[groups[0]]; !ok {
+		var next uint32
+		for _, n := range tradspoolMap {
+			if n >= next {
+				next = n + 1
+			}
+		}
+		if err := appendLine(l.path("tradspool.map"), "%s %d", groups[0], next); err != nil {
+			return err
+		}
+		tradspoolMap[groups[0]] = next
+	}
+
+	if err := l.writeActive(entries); err != nil {
+		return err
+	}
+
+	xref := []string{l.Hostname}
+	for _, name := range groups {
+		xref = append(xref, fmt.Sprintf("%s:%d", name, numbers[name]))
+	}
+	header := make(textproto.MIMEHeader, len(article.MIMEHeader)+1)
+	for k, v := range article.MIMEHeader {
+		header[k] = v
+	}
+	header.Set("Xref", strings.Join(xref, " "))
+
+	body, err := io.ReadAll(article.Body)
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	if err := writeHeader(&b, header); err != nil {
+		return err
+	}
+	b.Write(body)
+
+	// The article is stored once in its first group and hard linked into the others like INN does for crossposts
+	first := l.articlePath(groups[0], numbers[groups[0]])
+	if err := os.MkdirAll(filepath.Dir(first), 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(first, b.Bytes()); err != nil {
+		return err
+	}
+	for _, name := range groups[1:] {
+		path := l.articlePath(name, numbers[name])
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.Link(first, path); err != nil {
+			if err := writeFileAtomic(path, b.Bytes()); err != nil {
+				return err
+			}
+		}
+	}
+
+	arrived := time.Now().Unix()
+	posted := arrived
+	if date, err := mail.ParseDate(article.Get("Date")); err == nil {
+		posted = date.Unix()
+	}
+	token := encodeToken(tradspoolMap[groups[0]], uint32(numbers[groups[0]]))
+	if err := appendLine(l.path("history"), "%s\t%d~-~%d\t%s", hashMessageID(id), arrived, posted, token); err != nil {
+		return err
+	}
+
+	l.historyMu.Lock()
+	l.history[hashMessageID(id)] = token
+	l.historyMu.Unlock()
+	// If the overview database is being built this waits for it to finish, adding an article it already holds is harmless
+	l.overviewMu.Lock()
+	if l.overview != nil {
+		l.overview.Add(nntp.NewOverview(0, header, body), numbers)
+	}
+	l.overviewMu.Unlock()
+	return nil
 }
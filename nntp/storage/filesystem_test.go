@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"io"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Chemiseblanc/gonews/nntp"
+)
+
+// newTestFileSystem creates a tradspool backend in a temporary directory holding the given groups
+func newTestFileSystem(t *testing.T, groups ...string) *LegacyFileSystem {
+	t.Helper()
+	l := NewLegacyFileSystem(t.TempDir())
+	l.Hostname = "news.example.com"
+	for _, name := range groups {
+		if err := l.AddGroup(nntp.Group{Name: name, Description: "The " + name + " group"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l
+}
+
+// testArticle creates an article with the given message-id posted to the given groups
+func testArticle(id string, newsgroups string) nntp.Article {
+	header := textproto.MIMEHeader{}
+	header.Set("Message-ID", id)
+	header.Set("Newsgroups", newsgroups)
+	header.Set("Subject", "Test "+id)
+	header.Set("From", "tester@example.com")
+	header.Set("Date", "Mon, 02 Jan 2006 15:04:05 +0000")
+	return nntp.Article{MIMEHeader: header, Body: strings.NewReader("Hello\nWorld\n")}
+}
+
+func TestTokenRoundTrip(t *testing.T) {
+	token := encodeToken(7, 123456)
+	if len(token) != 38 {
+		t.Fatalf("token %s has length %d, want 38", token, len(token))
+	}
+	group, number, ok := decodeToken(token)
+	if !ok || group != 7 || number != 123456 {
+		t.Errorf("decodeToken(%s) = %d, %d, %v, want 7, 123456, true", token, group, number, ok)
+	}
+	if _, _, ok := decodeToken("@" + strings.Repeat("0", 36) + "@"); ok {
+		t.Error("decodeToken accepted a token of another storage method")
+	}
+}
+
+func TestHashMessageID(t *testing.T) {
+	if hashMessageID("<abc@EXAMPLE.com>") != hashMessageID("<abc@example.com>") {
+		t.Error("domain of a message-id is not case insensitive")
+	}
+	if hashMessageID("<ABC@example.com>") == hashMessageID("<abc@example.com>") {
+		t.Error("local part of a message-id is case insensitive")
+	}
+}
+
+func TestLegacyFileSystemRoundTrip(t *testing.T) {
+	l := newTestFileSystem(t, "comp.lang.go", "misc.test")
+	if err := l.PostArticle(testArticle("<one@example.com>", "comp.lang.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.PostArticle(testArticle("<two@example.com>", "comp.lang.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A new backend on the same spool reads everything back from the active, history and tradspool.map files
+	reopened := NewLegacyFileSystem(l.Root)
+	g := reopened.Group("comp.lang.go")
+	if g == nil || g.Min != 1 || g.Max != 2 || g.Count != 2 || g.Description != "The comp.lang.go group" {
+		t.Fatalf("Group = %+v, want articles 1 to 2", g)
+	}
+	if g.Created.IsZero() || g.Creator != "news" {
+		t.Errorf("Group = %+v, want the creation time and creator from active.times", g)
+	}
+	if !reopened.HasArticle("<two@example.com>") || reopened.HasArticle("<three@example.com>") {
+		t.Error("HasArticle doesn't match the history file")
+	}
+
+	a, err := reopened.ArticleByID("<two@example.com>")
+	if err != nil || a == nil {
+		t.Fatalf("ArticleByID = %v, %v", a, err)
+	}
+	if xref := a.Get("Xref"); xref != "news.example.com comp.lang.go:2" {
+		t.Errorf("Xref = %q", xref)
+	}
+	body, _ := io.ReadAll(a.Body)
+	if string(body) != "Hello\nWorld\n" {
+		t.Errorf("body = %q", body)
+	}
+
+	ov, err := reopened.OverviewByID("<one@example.com>")
+	if err != nil || ov == nil || ov.Subject != "Test <one@example.com>" || ov.Lines != 2 {
+		t.Errorf("OverviewByID = %+v, %v, want the overview built from the spool", ov, err)
+	}
+
+	if err := reopened.PostArticle(testArticle("<one@example.com>", "misc.test")); err != ErrDuplicateArticle {
+		t.Errorf("reposting an article from the history file returned %v, want ErrDuplicateArticle", err)
+	}
+	if err := reopened.PostArticle(testArticle("<none@example.com>", "alt.missing")); err != ErrNoGroups {
+		t.Errorf("posting to a group that isn't carried returned %v, want ErrNoGroups", err)
+	}
+}
+
+func TestLegacyFileSystemCrosspost(t *testing.T) {
+	l := newTestFileSystem(t, "comp.lang.go", "misc.test")
+	if err := l.PostArticle(testArticle("<first@example.com>", "misc.test")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.PostArticle(testArticle("<cross@example.com>", "comp.lang.go, misc.test, comp.lang.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := os.Stat(l.articlePath("comp.lang.go", 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := os.Stat(l.articlePath("misc.test", 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !os.SameFile(first, second) {
+		t.Error("crossposted article is not hard linked between its groups")
+	}
+
+	a, err := l.ArticleByGroup(nntp.Group{Name: "misc.test"}, 2)
+	if err != nil || a == nil || a.Get("Xref") != "news.example.com comp.lang.go:1 misc.test:2" {
+		t.Fatalf("ArticleByGroup = %v, %v", a, err)
+	}
+
+	for group, number := range map[string]uint{"comp.lang.go": 1, "misc.test": 2} {
+		overviews, err := l.OverviewByGroup(nntp.Group{Name: group}, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		found := false
+		for _, ov := range overviews {
+			found = found || (ov.Number == number && ov.MessageID == "<cross@example.com>")
+		}
+		if !found {
+			t.Errorf("OverviewByGroup(%s) = %+v, want the crosspost as number %d", group, overviews, number)
+		}
+	}
+}
+
+func TestLegacyFileSystemArticlesSince(t *testing.T) {
+	l := newTestFileSystem(t, "misc.test")
+	before := time.Now().Add(-time.Second)
+	if err := l.PostArticle(testArticle("<new@example.com>", "misc.test")); err != nil {
+		t.Fatal(err)
+	}
+
+	arrivals, err := l.ArticlesSince(before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(arrivals) != 1 || arrivals[0].MessageID != "<new@example.com>" || len(arrivals[0].Newsgroups) != 1 {
+		t.Errorf("ArticlesSince = %+v", arrivals)
+	}
+	if arrivals, _ := l.ArticlesSince(time.Now().Add(time.Hour)); len(arrivals) != 0 {
+		t.Errorf("ArticlesSince in the future = %+v, want none", arrivals)
+	}
+}
+
+func TestLegacyFileSystemRetriesLoading(t *testing.T) {
+	l := newTestFileSystem(t, "misc.test")
+	// A history file that can't be read fails the lookup without the failure being remembered
+	if err := os.Mkdir(l.path("history"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.ArticleByID("<one@example.com>"); err == nil {
+		t.Fatal("ArticleByID succeeded with an unreadable history file")
+	}
+	if _, err := l.OverviewByID("<one@example.com>"); err == nil {
+		t.Fatal("OverviewByID succeeded with an unreadable history file")
+	}
+
+	if err := os.Remove(l.path("history")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.PostArticle(testArticle("<one@example.com>", "misc.test")); err != nil {
+		t.Fatalf("PostArticle once the history file could be read = %v", err)
+	}
+	ov, err := l.OverviewByID("<one@example.com>")
+	if err != nil || ov == nil {
+		t.Errorf("OverviewByID once the history file could be read = %+v, %v", ov, err)
+	}
+}
@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/textproto"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Chemiseblanc/gonews/nntp"
+)
+
+// Memory is a concurrency-safe storage backend that holds groups and articles in memory,
+// it is intended for tests and servers that don't need to keep articles across restarts
+type Memory struct {
+	*OverviewDB
+	*ArrivalIndex
+
+	// Hostname is used as the path identity in the Xref header of posted articles
+	Hostname string
+
+	mu       sync.RWMutex
+	groups   map[string]*memoryGroup
+	articles map[string]*memoryArticle
+}
+
+type memoryGroup struct {
+	group    nntp.Group
+	articles map[uint]*memoryArticle
+}
+
+type memoryArticle struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// NewMemory creates an empty in-memory storage backend
+func NewMemory() *Memory {
+	return &Memory{
+		OverviewDB:   NewOverviewDB(),
+		ArrivalIndex: NewArrivalIndex(),
+		Hostname:     "localhost",
+		groups:       make(map[string]*memoryGroup),
+		articles:     make(map[string]*memoryArticle),
+	}
+}
+
+// AddGroup creates a group using the name, description, flag and creator of g, the article counters are ignored.
+// The creation time is set to the current time unless g specifies one, and an empty creator is recorded as "news".
+// Adding a group that already exists updates its description and flag
+func (m *Memory) AddGroup(g nntp.Group) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if existing, ok := m.groups[g.Name]; ok {
+		existing.group.Description = g.Description
+		existing.group.Flag = g.Flag
+		return
+	}
+	if g.Flag == "" {
+		g.Flag = "y"
+	}
+	if g.Created.IsZero() {
+		g.Created = time.Now()
+	}
+	if g.Creator == "" {
+		g.Creator = "news"
+	}
+	m.groups[g.Name] = &memoryGroup{
+		group: nntp.Group{
+			Name:        g.Name,
+			Description: g.Description,
+			Min:         1,
+			Max:         0,
+			Flag:        g.Flag,
+			Created:     g.Created,
+			Creator:     g.Creator,
+		},
+		articles: make(map[uint]*memoryArticle),
+	}
+}
+
+func (a *memoryArticle) article() *nntp.Article {
+	header := make(textproto.MIMEHeader, len(a.header))
+	for k, v := range a.header {
+		header[k] = append([]string(nil), v...)
+	}
+	return &nntp.Article{
+		MIMEHeader: header,
+		Body:       bytes.NewReader(a.body),
+	}
+}
+
+// HasArticle reports whether an article with the given message-id has been posted
+func (m *Memory) HasArticle(id string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, ok := m.articles[id]
+	return ok
+}
+
+// Group returns the group with the given name, or nil if it doesn't exist
+func (m *Memory) Group(name string) *nntp.Group {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if g, ok := m.groups[name]; ok {
+		group := g.group
+		return &group
+	}
+	return nil
+}
+
+// Groups returns every group ordered by name
+func (m *Memory) Groups() ([]nntp.Group, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	groups := make([]nntp.Group, 0, len(m.groups))
+	for _, g := range m.groups {
+		groups = append(groups, g.group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Name < groups[j].Name
+	})
+	return groups, nil
+}
+
+// CreationTimes reports that groups record when they were added
+func (m *Memory) CreationTimes() bool {
+	return true
+}
+
+// ArticleByID returns the article with the given message-id, or nil if there is no such article
+func (m *Memory) ArticleByID(id string) (*nntp.Article, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if a, ok := m.articles[id]; ok {
+		return a.article(), nil
+	}
+	return nil, nil
+}
+
+// ArticleByGroup returns the article with the given number in a group, or nil if there is no such article
+func (m *Memory) ArticleByGroup(group nntp.Group, number uint) (*nntp.Article, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if g, ok := m.groups[group.Name]; ok {
+		if a, ok := g.articles[number]; ok {
+			return a.article(), nil
+		}
+	}
+	return nil, nil
+}
+
+// ArticleNumbers returns the numbers of the articles in a group between low and high inclusive in ascending order
+func (m *Memory) ArticleNumbers(group nntp.Group, low uint, high uint) ([]uint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	numbers := make([]uint, 0)
+	if g, ok := m.groups[group.Name]; ok {
+		for number := range g.articles {
+			if number >= low && number <= high {
+				numbers = append(numbers, number)
+			}
+		}
+	}
+	sort.Slice(numbers, func(i, j int) bool {
+		return numbers[i] < numbers[j]
+	})
+	return numbers, nil
+}
+
+// PostArticle assigns the article the next number in each existing group named by its Newsgroups header,
+// records the numbers in its Xref header and adds it to the overview database and arrival index
+func (m *Memory) PostArticle(article nntp.Article) error {
+	id := article.MessageID()
+	if id == "" {
+		return ErrNoMessageID
+	}
+	body, err := io.ReadAll(article.Body)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.articles[id]; ok {
+		return ErrDuplicateArticle
+	}
+
+	var groups []*memoryGroup
+	for _, name := range strings.Split(article.Get("Newsgroups"), ",") {
+		g, ok := m.groups[strings.TrimSpace(name)]
+		if !ok || g.group.Flag == "x" {
+			continue
+		}
+		duplicate := false
+		for _, existing := range groups {
+			duplicate = duplicate || existing == g
+		}
+		if !duplicate {
+			groups = append(groups, g)
+		}
+	}
+	if len(groups) == 0 {
+		return ErrNoGroups
+	}
+
+	header := make(textproto.MIMEHeader, len(article.MIMEHeader)+1)
+	for k, v := range article.MIMEHeader {
+		header[k] = append([]string(nil), v...)
+	}
+	a := &memoryArticle{header: header, body: body}
+
+	xref := []string{m.Hostname}
+	numbers := make(map[string]uint, len(groups))
+	for _, g := range groups {
+		g.group.Max++
+		if g.group.Count == 0 {
+			g.group.Min = g.group.Max
+		}
+		g.group.Count++
+		g.articles[g.group.Max] = a
+		numbers[g.group.Name] = g.group.Max
+		xref = append(xref, fmt.Sprintf("%s:%d", g.group.Name, g.group.Max))
+	}
+	header.Set("Xref", strings.Join(xref, " "))
+	m.articles[id] = a
+
+	m.OverviewDB.Add(nntp.NewOverview(0, header, body), numbers)
+
+	arrival := nntp.Arrival{MessageID: id, Time: time.Now()}
+	for _, g := range groups {
+		arrival.Newsgroups = append(arrival.Newsgroups, g.group.Name)
+	}
+	m.ArrivalIndex.Add(arrival)
+	return nil
+}
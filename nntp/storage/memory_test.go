@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Chemiseblanc/gonews/nntp"
+)
+
+func newTestMemory(groups ...string) *Memory {
+	m := NewMemory()
+	m.Hostname = "news.example.com"
+	for _, name := range groups {
+		m.AddGroup(nntp.Group{Name: name})
+	}
+	return m
+}
+
+func TestMemoryNumbering(t *testing.T) {
+	m := newTestMemory("misc.test")
+	if g := m.Group("misc.test"); g.Count != 0 || g.Min != 1 || g.Max != 0 {
+		t.Errorf("empty group = %+v, want min 1 and max 0", g)
+	}
+
+	for i := 1; i <= 3; i++ {
+		if err := m.PostArticle(testArticle(fmt.Sprintf("<%d@example.com>", i), "misc.test")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if g := m.Group("misc.test"); g.Count != 3 || g.Min != 1 || g.Max != 3 {
+		t.Errorf("group = %+v, want articles 1 to 3", g)
+	}
+
+	numbers, err := m.ArticleNumbers(nntp.Group{Name: "misc.test"}, 2, 10)
+	if err != nil || len(numbers) != 2 || numbers[0] != 2 || numbers[1] != 3 {
+		t.Errorf("ArticleNumbers = %v, %v, want [2 3]", numbers, err)
+	}
+	a, err := m.ArticleByGroup(nntp.Group{Name: "misc.test"}, 2)
+	if err != nil || a == nil || a.MessageID() != "<2@example.com>" {
+		t.Fatalf("ArticleByGroup = %v, %v", a, err)
+	}
+	if xref := a.Get("Xref"); xref != "news.example.com misc.test:2" {
+		t.Errorf("Xref = %q", xref)
+	}
+	if a, _ := m.ArticleByGroup(nntp.Group{Name: "misc.test"}, 4); a != nil {
+		t.Error("ArticleByGroup returned an article past the end of the group")
+	}
+}
+
+func TestMemoryCrosspost(t *testing.T) {
+	m := newTestMemory("comp.lang.go", "misc.test")
+	if err := m.PostArticle(testArticle("<first@example.com>", "misc.test")); err != nil {
+		t.Fatal(err)
+	}
+	// Groups listed twice or not carried by the server are skipped
+	if err := m.PostArticle(testArticle("<cross@example.com>", "comp.lang.go,alt.missing, misc.test,comp.lang.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := m.ArticleByID("<cross@example.com>")
+	if err != nil || a == nil {
+		t.Fatalf("ArticleByID = %v, %v", a, err)
+	}
+	if xref := a.Get("Xref"); xref != "news.example.com comp.lang.go:1 misc.test:2" {
+		t.Errorf("Xref = %q", xref)
+	}
+	if g := m.Group("comp.lang.go"); g.Count != 1 || g.Max != 1 {
+		t.Errorf("comp.lang.go = %+v, want a single article", g)
+	}
+
+	for group, number := range map[string]uint{"comp.lang.go": 1, "misc.test": 2} {
+		overviews, err := m.OverviewByGroup(nntp.Group{Name: group}, number, number)
+		if err != nil || len(overviews) != 1 || overviews[0].MessageID != "<cross@example.com>" {
+			t.Errorf("OverviewByGroup(%s) = %+v, %v, want the crosspost", group, overviews, err)
+		}
+	}
+}
+
+func TestMemoryRejectsPosts(t *testing.T) {
+	m := newTestMemory("misc.test")
+	m.AddGroup(nntp.Group{Name: "misc.removed", Flag: "x"})
+	if err := m.PostArticle(testArticle("<one@example.com>", "misc.test")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.PostArticle(testArticle("<one@example.com>", "misc.test")); err != ErrDuplicateArticle {
+		t.Errorf("duplicate post returned %v, want ErrDuplicateArticle", err)
+	}
+	if err := m.PostArticle(testArticle("<two@example.com>", "misc.removed,alt.missing")); err != ErrNoGroups {
+		t.Errorf("post to no carried groups returned %v, want ErrNoGroups", err)
+	}
+	if err := m.PostArticle(testArticle("", "misc.test")); err != ErrNoMessageID {
+		t.Errorf("post without a message-id returned %v, want ErrNoMessageID", err)
+	}
+	if g := m.Group("misc.test"); g.Count != 1 || g.Max != 1 {
+		t.Errorf("rejected posts changed the group to %+v", g)
+	}
+}
+
+func TestMemoryConcurrentPosts(t *testing.T) {
+	m := newTestMemory("comp.lang.go", "misc.test")
+
+	const posts = 50
+	var wg sync.WaitGroup
+	for i := 0; i < posts; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Every article is posted twice concurrently, only one of which may succeed
+			id := fmt.Sprintf("<%d@example.com>", i/2)
+			err := m.PostArticle(testArticle(id, "comp.lang.go,misc.test"))
+			if err != nil && err != ErrDuplicateArticle {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, name := range []string{"comp.lang.go", "misc.test"} {
+		g := m.Group(name)
+		if g.Count != posts/2 || g.Min != 1 || g.Max != posts/2 {
+			t.Errorf("%s = %+v, want articles 1 to %d", name, g, posts/2)
+		}
+		numbers, _ := m.ArticleNumbers(*g, 1, g.Max)
+		if len(numbers) != posts/2 {
+			t.Errorf("%s has %d articles, want %d", name, len(numbers), posts/2)
+		}
+	}
+	arrivals, _ := m.ArticlesSince(time.Time{})
+	if len(arrivals) != posts/2 {
+		t.Errorf("arrival index holds %d articles, want %d", len(arrivals), posts/2)
+	}
+}
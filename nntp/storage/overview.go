@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/Chemiseblanc/gonews/nntp"
+)
+
+// OverviewDB is a concurrency-safe in-memory overview database. Storage backends record an entry for every article
+// accepted by PostArticle and can embed it to implement the nntp.OverviewStorage interface
+type OverviewDB struct {
+	mu     sync.RWMutex
+	groups map[string]map[uint]nntp.Overview
+	ids    map[string]nntp.Overview
+}
+
+// NewOverviewDB creates an empty overview database
+func NewOverviewDB() *OverviewDB {
+	return &OverviewDB{
+		groups: make(map[string]map[uint]nntp.Overview),
+		ids:    make(map[string]nntp.Overview),
+	}
+}
+
+// Add records the overview entry of an article under each of the article numbers it was assigned,
+// numbers maps the name of every group the article was filed in to its number in that group
+func (db *OverviewDB) Add(ov nntp.Overview, numbers map[string]uint) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for group, number := range numbers {
+		entries, ok := db.groups[group]
+		if !ok {
+			entries = make(map[uint]nntp.Overview)
+			db.groups[group] = entries
+		}
+		ov.Number = number
+		entries[number] = ov
+	}
+	ov.Number = 0
+	db.ids[ov.MessageID] = ov
+}
+
+// OverviewFormat returns the fields held in the overview database
+func (db *OverviewDB) OverviewFormat() []string {
+	return nntp.DefaultOverviewFormat
+}
+
+// OverviewByGroup returns the overview entries of the articles in a group numbered between low and high inclusive
+func (db *OverviewDB) OverviewByGroup(group nntp.Group, low uint, high uint) ([]nntp.Overview, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	entries := db.groups[group.Name]
+	overviews := make([]nntp.Overview, 0)
+	for number, ov := range entries {
+		if number >= low && number <= high {
+			overviews = append(overviews, ov)
+		}
+	}
+	sort.Slice(overviews, func(i, j int) bool {
+		return overviews[i].Number < overviews[j].Number
+	})
+	return overviews, nil
+}
+
+// OverviewByID returns the overview entry of an article, or nil if there is no such article
+func (db *OverviewDB) OverviewByID(id string) (*nntp.Overview, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if ov, ok := db.ids[id]; ok {
+		return &ov, nil
+	}
+	return nil, nil
+}
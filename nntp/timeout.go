@@ -0,0 +1,80 @@
+package nntp
+
+import (
+	"io"
+	"net"
+	"time"
+)
+
+const (
+	// DefaultIdleTimeout is the time a client may wait between commands if the server doesn't set an IdleTimeout,
+	// section 3.1 of RFC3977 requires it to be at least three minutes
+	DefaultIdleTimeout = 10 * time.Minute
+	// DefaultReadTimeout is the time a client has to finish sending a line once it has started if the server doesn't
+	// set a ReadTimeout
+	DefaultReadTimeout = time.Minute
+	// DefaultArticleTimeout is the time a client has to send an article to POST, IHAVE or TAKETHIS if the server
+	// doesn't set an ArticleTimeout
+	DefaultArticleTimeout = 10 * time.Minute
+	// DefaultWriteTimeout is the time a client has to accept each part of a response if the server doesn't set a
+	// WriteTimeout
+	DefaultWriteTimeout = 2 * time.Minute
+)
+
+// timeout returns d if it is positive, otherwise the default
+func timeout(d time.Duration, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
+
+// isTimeout reports whether an error was caused by a connection deadline passing
+func isTimeout(err error) bool {
+	e, ok := err.(net.Error)
+	return ok && e.Timeout()
+}
+
+// connWriter writes to the connection's current socket, which changes after STARTTLS, extending the write deadline
+// before each write so a client that stops reading a long response is disconnected
+type connWriter struct {
+	c *Conn
+}
+
+func (w connWriter) Write(p []byte) (int, error) {
+	if err := w.c.Conn.SetWriteDeadline(time.Now().Add(timeout(w.c.server.WriteTimeout, DefaultWriteTimeout))); err != nil {
+		return 0, err
+	}
+	return w.c.Conn.Write(p)
+}
+
+// timeoutReader records that the client took too long to send an article that is being read by the storage backend,
+// which may not return the error to the handler, so the server can disconnect the client afterwards
+type timeoutReader struct {
+	io.Reader
+	c *Conn
+}
+
+func (r timeoutReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if isTimeout(err) {
+		r.c.timedOut = true
+	}
+	return n, err
+}
+
+// waitForCommand blocks until the client starts sending its next command, or fails once the idle timeout has passed
+func (c *Conn) waitForCommand() error {
+	if err := c.SetReadDeadline(time.Now().Add(timeout(c.server.IdleTimeout, DefaultIdleTimeout))); err != nil {
+		return err
+	}
+	_, err := c.br.Peek(1)
+	return err
+}
+
+// sendTimeout tells the client its connection is being closed because it took too long, as described in section
+// 3.1 of RFC3977. This is best effort since a client that stopped reading won't receive the response
+func (c *Conn) sendTimeout() {
+	c.WriteLine(ResponseText(ResponseServiceUnavailable))
+	c.bw.Flush()
+}
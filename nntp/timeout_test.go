@@ -0,0 +1,63 @@
+package nntp
+
+import (
+	"testing"
+	"time"
+)
+
+const testTimeout = 20 * time.Millisecond
+
+func TestIdleTimeout(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.IdleTimeout = testTimeout
+	c := dialTest(t, &srv)
+
+	c.expect("400")
+	c.expectClosed()
+}
+
+func TestReadTimeout(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ReadTimeout = testTimeout
+	c := dialTest(t, &srv)
+
+	// A command that is started but never finished
+	c.send("HEL")
+	c.expect("400")
+	c.expectClosed()
+}
+
+func TestArticleTimeout(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}), WithAuth(feedAuth{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.ArticleTimeout = testTimeout
+	c := dialTest(t, &srv)
+
+	c.send("IHAVE <slow@example.com>\r\n")
+	c.expect("335")
+	c.send("Message-ID: <slow@example.com>\r\n")
+	c.expect("400")
+	c.expectClosed()
+}
+
+func TestWriteTimeout(t *testing.T) {
+	srv, err := NewServer("", nil, WithStorage(testStorage{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.WriteTimeout = testTimeout
+	c := dialTest(t, &srv)
+
+	// The client stops reading, so the server can't even send it 400 before disconnecting
+	c.send("HELP\r\n")
+	time.Sleep(5 * testTimeout)
+	c.expectClosed()
+}
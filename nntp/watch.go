@@ -0,0 +1,122 @@
+package nntp
+
+import (
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// aLongTimeAgo is a read deadline that has already passed, setting it interrupts a blocked read
+var aLongTimeAgo = time.Unix(1, 0)
+
+// connReader reads from the connection's current socket, which changes after STARTTLS. Since commands are handled
+// synchronously nothing reads from the client while a handler waits on a backend, so once the handler asks for the
+// connection's context the reader watches for the client disconnecting by reading a byte ahead in the background.
+// If that read fails the context is cancelled, and a byte that was received is returned by the next Read
+type connReader struct {
+	c *Conn
+
+	mu       sync.Mutex
+	cond     *sync.Cond
+	watching bool
+	hasByte  bool
+	byteBuf  [1]byte
+}
+
+func newConnReader(c *Conn) *connReader {
+	r := &connReader{c: c}
+	r.cond = sync.NewCond(&r.mu)
+	return r
+}
+
+func (r *connReader) Read(p []byte) (int, error) {
+	r.stopWatching()
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	r.mu.Lock()
+	if r.hasByte {
+		p[0] = r.byteBuf[0]
+		r.hasByte = false
+		r.mu.Unlock()
+		return 1, nil
+	}
+	r.mu.Unlock()
+	return r.c.Conn.Read(p)
+}
+
+// startWatching begins reading ahead in the background unless it is already doing so or input is waiting to be read
+func (r *connReader) startWatching() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.watching || r.hasByte || r.c.br.Buffered() > 0 {
+		return
+	}
+	r.watching = true
+	// Backends may take longer than the read timeout, which only applies while the server is waiting on the client
+	r.c.Conn.SetReadDeadline(time.Time{})
+	go r.backgroundRead()
+}
+
+func (r *connReader) backgroundRead() {
+	n, err := r.c.Conn.Read(r.byteBuf[:])
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if n == 1 {
+		r.hasByte = true
+	}
+	// A timeout means the read was interrupted by stopWatching, anything else means the client has gone away
+	if err != nil && !isTimeout(err) {
+		r.c.cancel()
+	}
+	r.watching = false
+	r.cond.Broadcast()
+}
+
+// stopWatching interrupts the background read and waits for it to finish, restoring the connection's read deadline
+func (r *connReader) stopWatching() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.watching {
+		return
+	}
+	r.c.Conn.SetReadDeadline(aLongTimeAgo)
+	for r.watching {
+		r.cond.Wait()
+	}
+	r.c.Conn.SetReadDeadline(r.c.readDeadline)
+}
+
+// takeByte stops watching and returns the byte read ahead from the socket, if there is one,
+// so that it can be passed on when the socket is taken over by STARTTLS
+func (r *connReader) takeByte() ([]byte, bool) {
+	r.stopWatching()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.hasByte {
+		return nil, false
+	}
+	r.hasByte = false
+	return []byte{r.byteBuf[0]}, true
+}
+
+// SetReadDeadline sets the deadline for reading from the client, stopping any background read first
+// so that it isn't overridden when the background read is interrupted
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.reader.stopWatching()
+	c.readDeadline = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+// prefixConn is a connection whose first reads return bytes that were read from it ahead of time
+type prefixConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c prefixConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
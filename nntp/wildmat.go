@@ -0,0 +1,64 @@
+package nntp
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// MatchWildmat reports whether name matches the wildmat as described in section 4 of RFC3977.
+// A wildmat is a comma separated list of patterns, each optionally prefixed with '!' to negate it.
+// The patterns are evaluated from right to left and the first pattern that matches decides the result.
+func MatchWildmat(wildmat string, name string) bool {
+	patterns := strings.Split(wildmat, ",")
+	for i := len(patterns) - 1; i >= 0; i-- {
+		pattern := patterns[i]
+		negated := false
+		if strings.HasPrefix(pattern, "!") {
+			negated = true
+			pattern = pattern[1:]
+		}
+		if matchPattern(pattern, name) {
+			return !negated
+		}
+	}
+	return false
+}
+
+// matchPattern matches a single wildmat pattern where '*' matches any sequence of characters
+// and '?' matches exactly one character. Only the most recent '*' is retried when a match fails,
+// which is sufficient since an earlier star can never need to absorb more of the name, keeping
+// the cost proportional to the product of the pattern and name lengths
+func matchPattern(pattern string, name string) bool {
+	p, n := 0, 0
+	starP, starN := -1, 0
+	for n < len(name) {
+		if p < len(pattern) {
+			switch pattern[p] {
+			case '*':
+				starP, starN = p, n
+				p++
+				continue
+			case '?':
+				_, size := utf8.DecodeRuneInString(name[n:])
+				p, n = p+1, n+size
+				continue
+			default:
+				if pattern[p] == name[n] {
+					p, n = p+1, n+1
+					continue
+				}
+			}
+		}
+		if starP < 0 {
+			return false
+		}
+		// Let the last star absorb one more character of the name and retry the rest of the pattern
+		_, size := utf8.DecodeRuneInString(name[starN:])
+		starN += size
+		p, n = starP+1, starN
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
@@ -0,0 +1,61 @@
+package nntp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMatchWildmat(t *testing.T) {
+	tests := []struct {
+		wildmat string
+		name    string
+		want    bool
+	}{
+		{"comp.lang.go", "comp.lang.go", true},
+		{"comp.lang.go", "comp.lang.go.misc", false},
+		{"comp.*", "comp.lang.go", true},
+		{"comp.*", "comp.", true},
+		{"comp.*", "comp", false},
+		{"*", "", true},
+		{"", "", true},
+		{"", "misc", false},
+		{"*.go", "comp.lang.go", true},
+		{"c*l*g*o", "comp.lang.go", true},
+		{"c*l*g*x", "comp.lang.go", false},
+		{"comp.lang.?o", "comp.lang.go", true},
+		{"comp.lang.?", "comp.lang.go", false},
+
+		// '?' matches a whole UTF-8 character rather than a single byte
+		{"de.?ber", "de.über", true},
+		{"de.??ber", "de.über", false},
+		{"*ü*", "de.über", true},
+
+		// Negation and right-to-left precedence
+		{"!comp.*", "comp.lang.go", false},
+		{"!comp.*", "misc.test", false},
+		{"*,!comp.*", "comp.lang.go", false},
+		{"*,!comp.*", "misc.test", true},
+		{"*,!comp.*,comp.lang.*", "comp.lang.go", true},
+		{"*,!comp.*,comp.lang.*", "comp.os.linux", false},
+		{"comp.lang.*,!comp.*", "comp.lang.go", false},
+	}
+	for _, test := range tests {
+		if got := MatchWildmat(test.wildmat, test.name); got != test.want {
+			t.Errorf("MatchWildmat(%q, %q) = %v, want %v", test.wildmat, test.name, got, test.want)
+		}
+	}
+}
+
+func TestMatchWildmatPathological(t *testing.T) {
+	wildmat := strings.Repeat("*a", 200) + "*b"
+	name := strings.Repeat("a", 10000)
+
+	start := time.Now()
+	if MatchWildmat(wildmat, name) {
+		t.Error("pattern requiring a trailing b matched a name without one")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("matching took %v", elapsed)
+	}
+}